@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+	"github.com/spf13/cobra"
+)
+
+type baselineOpt struct {
+	storeDir string
+
+	db        tidbAccessOptions
+	DB        string
+	queryFile string
+
+	compare compareOpt
+	report  reportOpt
+}
+
+func newBaselineCmd() *cobra.Command {
+	var opt baselineOpt
+	cmd := &cobra.Command{
+		Use:   "baseline",
+		Short: "record plans across runs and compare them",
+		Long:  `record plans of queries per TiDB version and date into a local store, and compare or list them later`,
+	}
+	cmd.PersistentFlags().StringVar(&opt.storeDir, "store-dir", "pcc-baseline", "directory of the baseline store")
+	cmd.AddCommand(newBaselineSaveCmd(&opt))
+	cmd.AddCommand(newBaselineDiffCmd(&opt))
+	cmd.AddCommand(newBaselineHistoryCmd(&opt))
+	return cmd
+}
+
+func newBaselineSaveCmd(opt *baselineOpt) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "save",
+		Short: "explain queries on the TiDB and save their plans into the store",
+		Long:  `explain queries on the TiDB and save their plans into the store`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBaselineSave(opt)
+		},
+	}
+	cmd.Flags().StringVar(&opt.db.addr, "addr", "127.0.0.1", "address of the target TiDB")
+	cmd.Flags().StringVar(&opt.db.port, "port", "4000", "port of the target TiDB")
+	cmd.Flags().StringVar(&opt.db.user, "user", "", "user name to access the target TiDB")
+	cmd.Flags().StringVar(&opt.db.password, "password", "", "password to access the target TiDB")
+	cmd.Flags().StringVar(&opt.db.version, "ver", "", "version of the target TiDB, it's read from the TiDB if empty")
+	cmd.Flags().StringVar(&opt.DB, "db", "mysql", "the default database when connecting to TiDB")
+	cmd.Flags().StringVar(&opt.queryFile, "query-file", "", "query file path")
+	return cmd
+}
+
+func newBaselineDiffCmd(opt *baselineOpt) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <versionA> <versionB>",
+		Short: "compare the latest saved plans of two versions",
+		Long:  `compare the latest saved plans of two versions`,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opt.report.check(); err != nil {
+				return err
+			}
+			store, err := openBaselineStore(opt.storeDir)
+			if err != nil {
+				return err
+			}
+			compareOpts, err := opt.compare.options()
+			if err != nil {
+				return err
+			}
+			result, err := diffBaseline(opt.report.logOut(), store, args[0], args[1], compareOpts...)
+			if err != nil {
+				return err
+			}
+			return writeReport(opt.report, result)
+		},
+	}
+	opt.compare.addFlags(cmd)
+	opt.report.addFlags(cmd)
+	return cmd
+}
+
+func newBaselineHistoryCmd(opt *baselineOpt) *cobra.Command {
+	return &cobra.Command{
+		Use:   "history <digest>",
+		Short: "list all saved plans of a query",
+		Long:  `list all saved plans of a query`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openBaselineStore(opt.storeDir)
+			if err != nil {
+				return err
+			}
+			records, err := store.history(args[0])
+			if err != nil {
+				return err
+			}
+			if len(records) == 0 {
+				return fmt.Errorf("no plan of digest %v in the store %v", args[0], opt.storeDir)
+			}
+			for _, r := range records {
+				printBaselineHistory(r)
+			}
+			return nil
+		},
+	}
+}
+
+func runBaselineSave(opt *baselineOpt) error {
+	store, err := openBaselineStore(opt.storeDir)
+	if err != nil {
+		return err
+	}
+	qs, err := scanQueryFile(opt.queryFile)
+	if err != nil {
+		return err
+	}
+	db, err := connectDB(opt.db, opt.DB)
+	if err != nil {
+		return fmt.Errorf("connect to DB error: %v", err)
+	}
+	defer db.db.Close()
+	if opt.db.version == "" {
+		if opt.db.version, err = db.getVersion(true); err != nil {
+			return err
+		}
+	}
+	sessions, err := newSessions(db, opt.DB, 1)
+	if err != nil {
+		return err
+	}
+	defer closeSessions(sessions)
+	s := sessions[0]
+
+	now := time.Now()
+	saved, errored := 0, 0
+	currentSchema := ""
+	for _, q := range qs {
+		if matchPrefixCaseInsensitive(q.SQL, "use") {
+			currentSchema = strings.Trim(strings.TrimSpace(q.SQL[len("use "):]), "`;")
+			continue
+		}
+		if q.Schema == "" {
+			q.Schema = currentSchema
+		}
+		sql := q.explainSQL()
+		if err := s.use(q.Schema); err != nil {
+			fmt.Printf("[PCC] run `use %v` for %v error=%v\n", q.Schema, sql, err)
+			errored++
+			continue
+		}
+		header, rows, err := runExplain(s.tidbHandler, sql)
+		if err != nil {
+			fmt.Printf("explain %v err=%v\n", sql, err)
+			errored++
+			continue
+		}
+		digest := q.digest()
+		e := baselineEntry{Version: opt.db.version, Date: now, Header: header, Rows: rows}
+		if err := store.save(q.Schema, sql, digest, e); err != nil {
+			return fmt.Errorf("save plan of %v error: %v", sql, err)
+		}
+		saved++
+	}
+	fmt.Printf("save %v plans of %v into %v, %v queries failed\n", saved, opt.db.version, opt.storeDir, errored)
+	return nil
+}
+
+// diffBaseline compares the latest plans of the two versions, queries without plans of both versions are ignored.
+func diffBaseline(out io.Writer, store *baselineStore, ver1, ver2 string, opts ...plan.CompareOption) (PlanCompareResult, error) {
+	records, err := store.records()
+	if err != nil {
+		return PlanCompareResult{}, err
+	}
+	var changes []SinglePlanCompareResult
+	var unchanged []UnchangedQuery
+	compared, errored := 0, 0
+	for _, r := range records {
+		e1, ok1 := r.latest(ver1)
+		e2, ok2 := r.latest(ver2)
+		if !ok1 || !ok2 {
+			continue
+		}
+		p1, err := r.plan(e1)
+		if err != nil {
+			fmt.Fprintf(out, "[PCC] parse plan of %v on %v error=%v\n", r.SQL, ver1, err)
+			errored++
+			continue
+		}
+		p2, err := r.plan(e2)
+		if err != nil {
+			fmt.Fprintf(out, "[PCC] parse plan of %v on %v error=%v\n", r.SQL, ver2, err)
+			errored++
+			continue
+		}
+		compared++
+		diff := plan.CompareDetailed(p1, p2, opts...)
+		if diff.Same() && len(diff.EstRowsDrifts) == 0 {
+			unchanged = append(unchanged, UnchangedQuery{SQL: r.SQL, Digest: r.Digest, Schema: r.Schema})
+			continue
+		}
+		c := newCompareResult(p1, p2, diff)
+		c.SQL, c.Digest, c.Schema = r.SQL, r.Digest, r.Schema
+		c.OldPlan, c.NewPlan = p1.PlanText, p2.PlanText
+		c.NewVersion = ver2
+		changes = append(changes, c)
+	}
+	result := PlanCompareResult{NewVersion: ver2, Summary: summarizeResults(changes), Results: changes, Unchanged: unchanged}
+	result.Summary.Total, result.Summary.Errored = compared, errored
+	return result, nil
+}
+
+// printBaselineHistory prints all plans of the record in time order, unchanged plans are folded.
+func printBaselineHistory(r *baselineRecord) {
+	fmt.Printf("digest: %v\n", r.Digest)
+	if r.Schema != "" {
+		fmt.Printf("schema: %v\n", r.Schema)
+	}
+	fmt.Printf("sql: %v\n", r.SQL)
+	lastPlan := ""
+	for _, e := range r.Entries {
+		planText := plan.FormatExplainRows(e.Rows)
+		if planText == lastPlan {
+			fmt.Printf("\n%v %v: plan unchanged\n", e.Date.Format(time.RFC3339), e.Version)
+			continue
+		}
+		fmt.Printf("\n%v %v:\n%v", e.Date.Format(time.RFC3339), e.Version, planText)
+		lastPlan = planText
+	}
+	fmt.Println()
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+// baselineStore is a directory of JSON files which records plans of queries across runs.
+// Plans of a query are stored in <dir>/<schema>/<digest>.json, queries without schema
+// are stored in the top directory.
+type baselineStore struct {
+	dir string
+}
+
+// baselineRecord contains all recorded plans of a query.
+type baselineRecord struct {
+	Digest  string          `json:"digest"`
+	Schema  string          `json:"schema"`
+	SQL     string          `json:"sql"`
+	Entries []baselineEntry `json:"entries"`
+}
+
+// baselineEntry is the explain result of a query on a TiDB version at some time.
+type baselineEntry struct {
+	Version string     `json:"version"`
+	Date    time.Time  `json:"date"`
+	Header  []string   `json:"header"`
+	Rows    [][]string `json:"rows"`
+}
+
+func openBaselineStore(dir string) (*baselineStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no baseline store directory")
+	}
+	if err := os.MkdirAll(dir, 0776); err != nil {
+		return nil, fmt.Errorf("create baseline store directory %v error: %v", dir, err)
+	}
+	return &baselineStore{dir: dir}, nil
+}
+
+func (s *baselineStore) recordPath(schema, digest string) string {
+	return filepath.Join(s.dir, schema, digest+".json")
+}
+
+// load returns nil if the query has no record.
+func (s *baselineStore) load(schema, digest string) (*baselineRecord, error) {
+	return readBaselineRecord(s.recordPath(schema, digest))
+}
+
+func readBaselineRecord(path string) (*baselineRecord, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	r := new(baselineRecord)
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("read baseline record %v error: %v", path, err)
+	}
+	return r, nil
+}
+
+// save records the plan of the query, a plan saved for the same version on the same day is replaced.
+func (s *baselineStore) save(schema, sql, digest string, e baselineEntry) error {
+	r, err := s.load(schema, digest)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		r = &baselineRecord{Digest: digest, Schema: schema}
+	}
+	r.SQL = sql
+	replaced := false
+	for i, old := range r.Entries {
+		if old.Version == e.Version && sameDay(old.Date, e.Date) {
+			r.Entries[i], replaced = e, true
+			break
+		}
+	}
+	if !replaced {
+		r.Entries = append(r.Entries, e)
+	}
+	sort.SliceStable(r.Entries, func(i, j int) bool { return r.Entries[i].Date.Before(r.Entries[j].Date) })
+
+	path := s.recordPath(schema, digest)
+	if err := os.MkdirAll(filepath.Dir(path), 0776); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func sameDay(t1, t2 time.Time) bool {
+	y1, m1, d1 := t1.Date()
+	y2, m2, d2 := t2.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
+
+// records returns all records in the store ordered by their schemas and digests.
+func (s *baselineStore) records() ([]*baselineRecord, error) {
+	var records []*baselineRecord
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		r, err := readBaselineRecord(path)
+		if err != nil {
+			return err
+		}
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Schema != records[j].Schema {
+			return records[i].Schema < records[j].Schema
+		}
+		return records[i].Digest < records[j].Digest
+	})
+	return records, nil
+}
+
+// history returns records of the digest in all schemas.
+func (s *baselineStore) history(digest string) ([]*baselineRecord, error) {
+	records, err := s.records()
+	if err != nil {
+		return nil, err
+	}
+	var matched []*baselineRecord
+	for _, r := range records {
+		if r.Digest == digest {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
+
+// latest returns the latest entry of the version, or the latest entry of all versions if version is empty.
+func (r *baselineRecord) latest(version string) (baselineEntry, bool) {
+	for i := len(r.Entries) - 1; i >= 0; i-- {
+		if version == "" || r.Entries[i].Version == version {
+			return r.Entries[i], true
+		}
+	}
+	return baselineEntry{}, false
+}
+
+func (r *baselineRecord) plan(e baselineEntry) (plan.Plan, error) {
+	p, err := plan.Parse(e.Version, r.SQL, e.Header, e.Rows)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	p.Schema = r.Schema
+	p.PlanText = plan.FormatExplainRows(e.Rows)
+	return p, nil
+}
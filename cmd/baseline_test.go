@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+func (s *loadTestSuite) TestBaselineStore(c *C) {
+	store, err := openBaselineStore(c.MkDir())
+	c.Assert(err, IsNil)
+
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	indexPlan := [][]string{
+		{"IndexLookUp_10", "10.00", "root", "", ""},
+		{"├─IndexRangeScan_8(Build)", "10.00", "cop[tikv]", "table:t, index:b(b)", "range:[10,10], keep order:false"},
+		{"└─TableRowIDScan_9(Probe)", "10.00", "cop[tikv]", "table:t", "keep order:false"},
+	}
+	tablePlan := [][]string{
+		{"TableReader_7", "10.00", "root", "", "data:Selection_6"},
+		{"└─Selection_6", "10.00", "cop[tikv]", "", "eq(test.t.b, 10)"},
+		{"  └─TableFullScan_5", "10000.00", "cop[tikv]", "table:t", "keep order:false"},
+	}
+	day1 := time.Date(2021, 1, 1, 10, 0, 0, 0, time.Local)
+	day2 := day1.Add(24 * time.Hour)
+	sql1, sql2 := "explain select * from t where b=10", "explain select * from t where b=20"
+
+	c.Assert(store.save("test", sql1, "d1", baselineEntry{Version: "v4.0.0", Date: day1, Header: header, Rows: tablePlan}), IsNil)
+	// a plan saved for the same version on the same day is replaced
+	c.Assert(store.save("test", sql1, "d1", baselineEntry{Version: "v4.0.0", Date: day1.Add(time.Hour), Header: header, Rows: indexPlan}), IsNil)
+	c.Assert(store.save("test", sql1, "d1", baselineEntry{Version: "v5.0.0", Date: day2, Header: header, Rows: tablePlan}), IsNil)
+	c.Assert(store.save("test", sql2, "d2", baselineEntry{Version: "v4.0.0", Date: day1, Header: header, Rows: indexPlan}), IsNil)
+	c.Assert(store.save("other", sql1, "d1", baselineEntry{Version: "v4.0.0", Date: day1, Header: header, Rows: indexPlan}), IsNil)
+
+	records, err := store.records()
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+	c.Assert(records[0].Schema, Equals, "other")
+
+	history, err := store.history("d1")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+	r := history[1]
+	c.Assert(r.Schema, Equals, "test")
+	c.Assert(r.Entries, HasLen, 2)
+	c.Assert(r.Entries[0].Rows, DeepEquals, indexPlan)
+	e, ok := r.latest("v5.0.0")
+	c.Assert(ok, IsTrue)
+	c.Assert(e.Date.Equal(day2), IsTrue)
+	_, ok = r.latest("v6.0.0")
+	c.Assert(ok, IsFalse)
+
+	result, err := diffBaseline(ioutil.Discard, store, "v4.0.0", "v5.0.0")
+	c.Assert(err, IsNil)
+	c.Assert(result.Summary.Total, Equals, 1)
+	c.Assert(result.Results, HasLen, 1)
+	c.Assert(result.Results[0].Digest, Equals, "d1")
+	c.Assert(result.Results[0].Schema, Equals, "test")
+	c.Assert(result.Results[0].Reason, Equals, "different operators IndexLookUp_10 and TableReader_7")
+	c.Assert(result.NewVersion, Equals, "v5.0.0")
+
+	result, err = diffBaseline(ioutil.Discard, store, "v4.0.0", "v4.0.0", plan.WithRules())
+	c.Assert(err, IsNil)
+	c.Assert(result.Summary.Total, Equals, 3)
+	c.Assert(result.Results, HasLen, 0)
+}
+
+func (s *loadTestSuite) TestQueryDigest(c *C) {
+	_, digest := parser.NormalizeDigest("select * from t where a = 1")
+	c.Assert(sqlDigest("explain select * from t where a = 1"), Equals, digest)
+	c.Assert(sqlDigest("EXPLAIN ANALYZE select * from t where a = 2"), Equals, digest)
+	c.Assert(Query{SQL: "explain select * from t where a = ?"}.digest(), Equals, digest)
+	c.Assert(Query{SQL: "explain select * from t where a = ?", Digest: "d1"}.digest(), Equals, "d1")
+	c.Assert(planDigest(plan.Plan{SQL: "explain select * from t where a = 3"}), Equals, digest)
+}
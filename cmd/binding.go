@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// bindingsFileName is the file storing global bindings in the schema-stats-dir.
+const bindingsFileName = "bindings.json"
+
+// binding is a global SQL binding which forces the plan of queries.
+type binding struct {
+	OriginalSQL string `json:"originalSQL"`
+	BindSQL     string `json:"bindSQL"`
+	DefaultDB   string `json:"defaultDB"`
+	Status      string `json:"status"`
+}
+
+// active returns whether the binding is used by the optimizer.
+func (b binding) active() bool {
+	status := strings.ToLower(b.Status)
+	return status == "using" || status == "enabled"
+}
+
+func bindingsPath(dir string) string {
+	return path.Join(dir, bindingsFileName)
+}
+
+// getGlobalBindings returns all active global bindings.
+func (db *tidbHandler) getGlobalBindings() ([]binding, error) {
+	rows, err := db.db.Query("show global bindings")
+	if err != nil {
+		return nil, fmt.Errorf("execute `show global bindings` error: %v", err)
+	}
+	defer rows.Close()
+	header, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var bindings []binding
+	for rows.Next() {
+		// columns are different among versions, so they are located by names
+		cols := make([]sql.NullString, len(header))
+		ptrs := make([]interface{}, len(header))
+		for i := range cols {
+			ptrs[i] = &cols[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("scan rows error: %v", err)
+		}
+		var b binding
+		for i, name := range header {
+			switch strings.ToLower(name) {
+			case "original_sql":
+				b.OriginalSQL = cols[i].String
+			case "bind_sql":
+				b.BindSQL = cols[i].String
+			case "default_db":
+				b.DefaultDB = cols[i].String
+			case "status":
+				b.Status = cols[i].String
+			}
+		}
+		if b.active() {
+			bindings = append(bindings, b)
+		}
+	}
+	return bindings, rows.Err()
+}
+
+// exportBindings writes global bindings of the DB, or all DBs if specDB is empty, into the directory.
+func exportBindings(db *tidbHandler, dir, specDB string) error {
+	bindings, err := db.getGlobalBindings()
+	if err != nil {
+		return err
+	}
+	var filtered []binding
+	for _, b := range bindings {
+		if specDB == "" || strings.EqualFold(b.DefaultDB, specDB) {
+			filtered = append(filtered, b)
+		}
+	}
+	data, err := json.MarshalIndent(filtered, "", "  ")
+	if err != nil {
+		return err
+	}
+	fpath := bindingsPath(dir)
+	fmt.Printf("export %v global bindings into %v\n", len(filtered), fpath)
+	return ioutil.WriteFile(fpath, data, 0666)
+}
+
+// readBindings returns nil if there is no bindings file in the directory.
+func readBindings(dir string) ([]binding, error) {
+	data, err := ioutil.ReadFile(bindingsPath(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var bindings []binding
+	if err := json.Unmarshal(data, &bindings); err != nil {
+		return nil, fmt.Errorf("read bindings from %v error: %v", bindingsPath(dir), err)
+	}
+	return bindings, nil
+}
+
+// importBindings creates global bindings stored in the directory, bindings failed to create are skipped.
+func importBindings(db *tidbHandler, specDB, dir string, out io.Writer) error {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil
+	}
+	bindings, err := readBindings(dir)
+	if err != nil || len(bindings) == 0 {
+		return err
+	}
+	sessions, err := newSessions(db, "", 1)
+	if err != nil {
+		return err
+	}
+	defer closeSessions(sessions)
+	s := sessions[0]
+	created := 0
+	for _, b := range bindings {
+		if specDB != "" && !strings.EqualFold(b.DefaultDB, specDB) {
+			continue
+		}
+		if err := s.use(b.DefaultDB); err != nil {
+			fmt.Fprintf(out, "[PCC]: skip binding %v, use %v error: %v\n", b.BindSQL, b.DefaultDB, err)
+			continue
+		}
+		createSQL := fmt.Sprintf("create global binding for %v using %v", b.OriginalSQL, b.BindSQL)
+		if _, err := s.db.Exec(createSQL); err != nil {
+			fmt.Fprintf(out, "[PCC]: skip binding %v, create binding error: %v\n", b.BindSQL, err)
+			continue
+		}
+		created++
+	}
+	fmt.Fprintf(out, "import %v global bindings from %v successfully\n", created, bindingsPath(dir))
+	return nil
+}
+
+// planFromBinding returns whether the plan of the last statement is produced under a binding,
+// it returns false if the TiDB doesn't support @@last_plan_from_binding.
+func (s *sessionHandler) planFromBinding() bool {
+	var fromBinding sql.NullInt64
+	if err := s.db.QueryRow("select @@last_plan_from_binding").Scan(&fromBinding); err != nil {
+		return false
+	}
+	return fromBinding.Int64 != 0
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/pingcap/check"
+)
+
+func (s *loadTestSuite) TestReadBindings(c *C) {
+	dir := c.MkDir()
+	bindings, err := readBindings(dir)
+	c.Assert(err, IsNil)
+	c.Assert(bindings, IsNil)
+
+	content := `[{"originalSQL": "select * from ` + "`test` . `t`" + ` where ` + "`b`" + ` = ?",
+  "bindSQL": "SELECT /*+ use_index(t, b)*/ * FROM test.t WHERE b = 10",
+  "defaultDB": "test", "status": "enabled"}]`
+	c.Assert(ioutil.WriteFile(bindingsPath(dir), []byte(content), 0644), IsNil)
+	bindings, err = readBindings(dir)
+	c.Assert(err, IsNil)
+	c.Assert(bindings, HasLen, 1)
+	c.Assert(bindings[0].DefaultDB, Equals, "test")
+	c.Assert(bindings[0].active(), IsTrue)
+	c.Assert(binding{Status: "using"}.active(), IsTrue)
+	c.Assert(binding{Status: "deleted"}.active(), IsFalse)
+
+	c.Assert(ioutil.WriteFile(bindingsPath(dir), []byte("{"), 0644), IsNil)
+	_, err = readBindings(dir)
+	c.Assert(err, NotNil)
+}
+
+func (s *loadTestSuite) TestReportBindingLost(c *C) {
+	result := reportTestResult(c)
+	lost := SinglePlanCompareResult{SQL: "explain select * from t where a=1", Same: true, OldBinding: true, BindingLost: true}
+	result.Results = append(result.Results, lost)
+	result.Summary = summarizeResults(result.Results)
+	c.Assert(result.Summary.BindingLost, Equals, 1)
+
+	var buf bytes.Buffer
+	c.Assert(writeTextReport(&buf, result), IsNil)
+	c.Assert(strings.Contains(buf.String(), "plan1 bound, plan2 not bound, the binding doesn't apply in the new version"), IsTrue)
+	c.Assert(strings.Contains(buf.String(), "1 queries lost their bindings"), IsTrue)
+
+	buf.Reset()
+	c.Assert(writeMarkdownReport(&buf, result), IsNil)
+	c.Assert(strings.Contains(buf.String(), "| Binding lost | 1 |"), IsTrue)
+	c.Assert(strings.Contains(buf.String(), "[none] binding lost</summary>"), IsTrue)
+
+	buf.Reset()
+	c.Assert(writeHTMLReport(&buf, result), IsNil)
+	c.Assert(strings.Contains(buf.String(), "<b>Binding:</b> plan1 bound, plan2 not bound"), IsTrue)
+
+	buf.Reset()
+	c.Assert(writeJUnitReport(&buf, result), IsNil)
+	c.Assert(strings.Contains(buf.String(), `message="binding lost"`), IsTrue)
+}
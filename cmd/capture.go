@@ -3,9 +3,12 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/pingcap/parser"
 	"github.com/qw4990/plan-change-capturer/plan"
@@ -20,6 +23,20 @@ type captureOpt struct {
 	DB         string
 	digestFlag bool
 	tables     []string
+	jsonFormat bool
+	compare    compareOpt
+
+	analyze         bool
+	preparedExecute bool
+	recordedPlans   bool
+	latencyRatio    float64
+	minLatency      time.Duration
+
+	concurrency int
+	checkpoint  checkpointOpt
+	report      reportOpt
+	// logOut is where progress and errors are printed, it's chosen by the report options.
+	logOut io.Writer
 }
 
 func newCaptureCmd() *cobra.Command {
@@ -29,6 +46,10 @@ func newCaptureCmd() *cobra.Command {
 		Short: "capture plan changes",
 		Long:  `capture plan changes`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opt.report.check(); err != nil {
+				return err
+			}
+			opt.logOut = opt.report.logOut()
 			opt.mode = strings.ToLower(opt.mode)
 			switch opt.mode {
 			case "online":
@@ -58,6 +79,16 @@ func newCaptureCmd() *cobra.Command {
 	cmd.Flags().StringVar(&opt.DB, "db", "mysql", "the default database when connecting to TiDB")
 	cmd.Flags().BoolVar(&opt.digestFlag, "digest-flag", false, "SQLs with the same digest only be printed once if it is true")
 	cmd.Flags().StringSliceVar(&opt.tables, "tables", nil, "tables to export")
+	cmd.Flags().BoolVar(&opt.jsonFormat, "json-format", false, "use EXPLAIN FORMAT='tidb_json' if the TiDB supports it")
+	cmd.Flags().BoolVar(&opt.analyze, "explain-analyze", false, "run EXPLAIN ANALYZE on both TiDB and compare their execution metrics, queries are executed actually")
+	cmd.Flags().BoolVar(&opt.preparedExecute, "prepared-execute", false, "run queries with parameters as prepared statements by PREPARE and EXECUTE on both TiDB and compare plans they actually use, queries are executed actually")
+	cmd.Flags().BoolVar(&opt.recordedPlans, "recorded-plans", false, "use plans recorded in the query file exported from the statement summary or slow logs as plans of the first TiDB instead of explaining queries on it")
+	cmd.Flags().Float64Var(&opt.latencyRatio, "latency-regression-ratio", 1.5, "report queries whose latency on the second TiDB is more than this ratio of the first one in EXPLAIN ANALYZE mode")
+	cmd.Flags().DurationVar(&opt.minLatency, "min-latency", 10*time.Millisecond, "ignore latency regressions of queries faster than this on the second TiDB")
+	cmd.Flags().IntVar(&opt.concurrency, "concurrency", 1, "number of workers explaining queries concurrently, each worker has its own connections")
+	opt.compare.addFlags(cmd)
+	opt.checkpoint.addFlags(cmd, "capture.checkpoint")
+	opt.report.addFlags(cmd)
 	return cmd
 }
 
@@ -70,13 +101,16 @@ func runCaptureOfflineMode(opt *captureOpt) error {
 			return fmt.Errorf("connect to DB1 error: %v", err)
 		}
 	} else {
-		db1, err = startDB(opt.db1)
+		db1, err = startDB(opt.db1, opt.logOut)
 		if err != nil {
 			return fmt.Errorf("start DB1 error: %v", err)
 		}
-		if err := importSchemaStats(db1, "", opt.schemaDir); err != nil {
+		if err := importSchemaStats(db1, "", opt.schemaDir, opt.logOut); err != nil {
 			return fmt.Errorf("import schema and stats into DB1 error: %v", err)
 		}
+		if err := importBindings(db1, "", opt.schemaDir, opt.logOut); err != nil {
+			return fmt.Errorf("import global bindings into DB1 error: %v", err)
+		}
 		if db1, err = connectDB(opt.db1, opt.DB); err != nil {
 			return fmt.Errorf("connect to DB1 error: %v", err)
 		}
@@ -89,13 +123,16 @@ func runCaptureOfflineMode(opt *captureOpt) error {
 			return fmt.Errorf("connect to DB2 error: %v", err)
 		}
 	} else {
-		db2, err = startDB(opt.db2)
+		db2, err = startDB(opt.db2, opt.logOut)
 		if err != nil {
 			return fmt.Errorf("start and DB2 error: %v", err)
 		}
-		if err := importSchemaStats(db2, "", opt.schemaDir); err != nil {
+		if err := importSchemaStats(db2, "", opt.schemaDir, opt.logOut); err != nil {
 			return fmt.Errorf("import schema and stats into DB2 error: %v", err)
 		}
+		if err := importBindings(db2, "", opt.schemaDir, opt.logOut); err != nil {
+			return fmt.Errorf("import global bindings into DB2 error: %v", err)
+		}
 		if db2, err = connectDB(opt.db2, opt.DB); err != nil {
 			return fmt.Errorf("connect to DB2 error: %v", err)
 		}
@@ -106,15 +143,18 @@ func runCaptureOfflineMode(opt *captureOpt) error {
 	if err != nil {
 		return err
 	}
-	return capturePlanChanges(db1, db2, sqls, opt.digestFlag)
+	return capturePlanChanges(db1, db2, sqls, opt)
 }
 
 func runCaptureOnlineMode(opt *captureOpt) error {
+	if opt.analyze {
+		fmt.Fprintln(opt.logOut, "[PCC] WARN: the second TiDB only has schemas and statistics in online mode, its execution metrics are not comparable")
+	}
 	db1, err := connectDB(opt.db1, opt.DB)
 	if err != nil {
 		return fmt.Errorf("connect to DB1 error: %v", err)
 	}
-	db2, err := startDB(opt.db2)
+	db2, err := startDB(opt.db2, opt.logOut)
 	if err != nil {
 		return fmt.Errorf("start and connect to DB2 error: %v", err)
 	}
@@ -127,9 +167,12 @@ func runCaptureOnlineMode(opt *captureOpt) error {
 	if err := exportSchemaStats(db1, dir, "", nil); err != nil {
 		return fmt.Errorf("export schema and stats from DB1 error: %v", err)
 	}
-	if err := importSchemaStats(db2, "", dir); err != nil {
+	if err := importSchemaStats(db2, "", dir, opt.logOut); err != nil {
 		return fmt.Errorf("import shcema and stats into DB2 error: %v", err)
 	}
+	if err := importBindings(db2, "", dir, opt.logOut); err != nil {
+		return fmt.Errorf("import global bindings into DB2 error: %v", err)
+	}
 	if db2, err = connectDB(opt.db2, opt.DB); err != nil {
 		return fmt.Errorf("connect to DB2 error: %v", err)
 	}
@@ -138,10 +181,14 @@ func runCaptureOnlineMode(opt *captureOpt) error {
 	if err != nil {
 		return err
 	}
-	return capturePlanChanges(db1, db2, sqls, opt.digestFlag)
+	return capturePlanChanges(db1, db2, sqls, opt)
 }
 
-func capturePlanChanges(db1, db2 *tidbHandler, qs []Query, digestFlag bool) error {
+func capturePlanChanges(db1, db2 *tidbHandler, qs []Query, opt *captureOpt) error {
+	compareOpts, err := opt.compare.options()
+	if err != nil {
+		return err
+	}
 	ver1, err := db1.getVersion(false)
 	if err != nil {
 		return err
@@ -150,102 +197,255 @@ func capturePlanChanges(db1, db2 *tidbHandler, qs []Query, digestFlag bool) erro
 	if err != nil {
 		return err
 	}
+	if opt.recordedPlans {
+		// recorded plans are parsed in the layout of the version reported by the first TiDB instead of --ver1
+		if db1.opt.version, err = db1.getVersion(true); err != nil {
+			return err
+		}
+	}
 
-	fmt.Printf("begin to capture plan changes between %v and %v\n", ver1, ver2)
-	defer fmt.Printf("finish capturing plan changes\n")
-	digests := make(map[string]struct{})
-
+	// resolve the database of each query first since queries are explained concurrently
+	var tasks []Query
 	currentSchema := ""
 	for _, q := range qs {
-		if strings.ToLower(currentSchema) != strings.ToLower(q.Schema) {
-			changeDBStmt := fmt.Sprintf("use `%v`", q.Schema)
-			if _, err := db1.db.Exec(changeDBStmt); err != nil {
-				fmt.Printf("[PCC] run `use %v` for %v error=%v\n", q.Schema, q.SQL, err)
-				continue
-			}
-			if _, err := db2.db.Exec(changeDBStmt); err != nil {
-				fmt.Printf("[PCC] run `use %v` for %v error=%v\n", q.Schema, q.SQL, err)
-				continue
-			}
+		if matchPrefixCaseInsensitive(q.SQL, "use") {
+			currentSchema = strings.Trim(strings.TrimSpace(q.SQL[len("use "):]), "`;")
+			continue
+		}
+		if !matchPrefixCaseInsensitive(q.SQL, "explain") {
+			return fmt.Errorf("unexpected SQL %v", q.SQL)
+		}
+		if q.Schema == "" {
+			q.Schema = currentSchema
 		}
+		tasks = append(tasks, q)
+	}
 
-		sql := q.SQL
-		if matchPrefixCaseInsensitive(sql, "use") {
-			dbName := strings.TrimSpace(sql[len("use "):])
-			changeDBStmt := fmt.Sprintf("use `%v`", dbName)
-			if _, err := db1.db.Exec(changeDBStmt); err != nil {
-				return err
-			}
-			if _, err := db2.db.Exec(changeDBStmt); err != nil {
-				return err
-			}
-		} else if matchPrefixCaseInsensitive(sql, "explain") {
-			_, digest := parser.NormalizeDigest(sql)
-			if digestFlag {
-				if _, ok := digests[digest]; ok {
-					continue
-				}
-			}
+	if opt.concurrency < 1 {
+		opt.concurrency = 1
+	}
+	sessions1, err := newSessions(db1, opt.DB, opt.concurrency)
+	if err != nil {
+		return err
+	}
+	defer closeSessions(sessions1)
+	sessions2, err := newSessions(db2, opt.DB, opt.concurrency)
+	if err != nil {
+		return err
+	}
+	defer closeSessions(sessions2)
 
-			var p1, p2 plan.Plan
-			var r1, r2 [][]string
-			var err error
-			r1, err = runExplain(db1, sql)
-			if err != nil {
-				fmt.Printf("run %v on db1 err=%v\n", sql, err)
-				continue
-			}
-			r2, err = runExplain(db2, sql)
-			if err != nil {
-				fmt.Printf("run %v on db2 err=%v\n", sql, err)
-				continue
-			}
-			p1, err = plan.Parse(db1.opt.version, sql, r1)
-			if err != nil {
-				fmt.Printf("parse %v err=%v\n", sql, err)
-				continue
-			}
-			p2, err = plan.Parse(db2.opt.version, sql, r2)
-			if err != nil {
-				fmt.Printf("parse %v err=%v\n", sql, err)
+	fmt.Fprintf(opt.logOut, "begin to capture plan changes between %v and %v\n", ver1, ver2)
+	defer fmt.Fprintf(opt.logOut, "finish capturing plan changes\n")
+	cp, err := openCheckpoint(opt.checkpoint, opt.logOut)
+	if err != nil {
+		return err
+	}
+	results := make([]captureResult, len(tasks))
+	// errors are printed in the order of queries as soon as they are known
+	progress := newProgressReporter("capture", len(tasks), opt.logOut, func(i int) {
+		if results[i].ErrMsg != "" {
+			fmt.Fprintln(opt.logOut, results[i].ErrMsg)
+		}
+	})
+	runWorkers(opt.concurrency, len(tasks), func(worker, i int) {
+		defer progress.finish(i)
+		key := checkpointKey(tasks[i].Schema, tasks[i].explainSQL())
+		if cp.get(i, key, &results[i]) {
+			return
+		}
+		results[i] = captureQuery(sessions1[worker], sessions2[worker], tasks[i], opt, compareOpts)
+		if results[i].ErrMsg != "" {
+			return // failed queries are retried when resuming
+		}
+		if err := cp.put(i, key, results[i]); err != nil {
+			fmt.Fprintf(opt.logOut, "[PCC] write checkpoint file error=%v\n", err)
+		}
+	})
+	// all results are flushed so that the run can be resumed if some queries failed or the report isn't written
+	if err := cp.flush(); err != nil {
+		fmt.Fprintf(opt.logOut, "[PCC] write checkpoint file error=%v\n", err)
+	}
+
+	// results are handled in the order of queries to make the output deterministic
+	digests := make(map[string]struct{})
+	var changes []SinglePlanCompareResult
+	var unchanged []UnchangedQuery
+	compared, errored, explained := 0, 0, 0
+	for i, r := range results {
+		if r.ErrMsg != "" {
+			errored++
+			continue
+		}
+		compared++
+		if opt.recordedPlans && tasks[i].Plan == "" {
+			explained++
+		}
+		if r.Result == nil {
+			q := tasks[i]
+			unchanged = append(unchanged, UnchangedQuery{SQL: q.explainSQL(), Digest: q.digest(), Schema: q.Schema})
+			continue
+		}
+		if opt.digestFlag {
+			if _, ok := digests[r.Result.Digest]; ok {
 				continue
 			}
-			if reason, same := plan.Compare(p1, p2); !same {
-				fmt.Println("=====================================================================")
-				fmt.Println("SQL: ")
-				fmt.Println(sql)
-				fmt.Println()
-				fmt.Println("Plan1: ")
-				fmt.Println(plan.FormatExplainRows(r1))
-				fmt.Println()
-				fmt.Println("Plan2: ")
-				fmt.Println(plan.FormatExplainRows(r2))
-				fmt.Println()
-				fmt.Println("Reason: ", reason)
-				fmt.Println("=====================================================================")
-
-				if digestFlag {
-					digests[digest] = struct{}{}
-				}
-			}
-		} else {
-			return fmt.Errorf("unexpected SQL %v", sql)
+			digests[r.Result.Digest] = struct{}{}
+		}
+		changes = append(changes, *r.Result)
+	}
+
+	// the most risky and drastic changes are printed first
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Severity != changes[j].Severity {
+			return changes[i].Severity > changes[j].Severity
 		}
+		if changes[i].LatencyRegressed != changes[j].LatencyRegressed {
+			return changes[i].LatencyRegressed
+		}
+		return changes[i].Similarity < changes[j].Similarity
+	})
+	result := PlanCompareResult{NewVersion: ver2, Summary: summarizeResults(changes), Results: changes, Unchanged: unchanged}
+	result.Summary.Total, result.Summary.Errored = compared, errored
+	result.Summary.OldPlanExplained = explained
+	if err := writeReport(opt.report, result); err != nil {
+		return err
+	}
+	if errored > 0 {
+		fmt.Fprintf(opt.logOut, "[PCC] %v queries failed, keep the checkpoint file to retry them by --resume\n", errored)
+		return nil
+	}
+	if err := cp.remove(); err != nil {
+		fmt.Fprintf(opt.logOut, "[PCC] remove checkpoint file error=%v\n", err)
 	}
 	return nil
 }
 
-func runExplain(h *tidbHandler, explainSQL string) ([][]string, error) {
+type captureResult struct {
+	// Result is nil if the plan is not changed
+	Result *SinglePlanCompareResult `json:"result,omitempty"`
+	ErrMsg string                   `json:"errMsg,omitempty"`
+}
+
+// captureQuery explains the query on both TiDB and compares their plans.
+func captureQuery(s1, s2 *sessionHandler, q Query, opt *captureOpt, compareOpts []plan.CompareOption) captureResult {
+	sql := q.explainSQL()
+	if err := s1.use(q.Schema); err != nil {
+		return captureResult{ErrMsg: fmt.Sprintf("[PCC] run `use %v` for %v error=%v", q.Schema, sql, err)}
+	}
+	if err := s2.use(q.Schema); err != nil {
+		return captureResult{ErrMsg: fmt.Sprintf("[PCC] run `use %v` for %v error=%v", q.Schema, sql, err)}
+	}
+
+	var p1 plan.Plan
+	var r1 [][]string
+	var bound1 bool
+	var err error
+	recorded := opt.recordedPlans && q.Plan != ""
+	if recorded {
+		if p1, r1, err = parseRecordedPlan(s1.opt.version, q); err != nil {
+			return captureResult{ErrMsg: fmt.Sprintf("parse the recorded plan of %v err=%v", sql, err)}
+		}
+		bound1 = q.PlanFromBinding
+	} else if p1, r1, bound1, err = explainQuery(s1, q, opt); err != nil {
+		return captureResult{ErrMsg: fmt.Sprintf("explain %v on db1 err=%v", sql, err)}
+	}
+	// the query has no recorded plan, so its old plan is explained on the first TiDB
+	oldPlanExplained := opt.recordedPlans && !recorded
+	p2, r2, bound2, err := explainQuery(s2, q, opt)
+	if err != nil {
+		return captureResult{ErrMsg: fmt.Sprintf("explain %v on db2 err=%v", sql, err)}
+	}
+	if recorded {
+		// the recorded plan is of the same query but its SQL is not in the explained form
+		p1.SQL = p2.SQL
+	}
+	diff := plan.CompareDetailed(p1, p2, compareOpts...)
+	var runtime *plan.RuntimeDiff
+	regressed := false
+	if rd, ok := plan.CompareRuntime(p1, p2); ok {
+		runtime, regressed = &rd, rd.Regressed(opt.latencyRatio, opt.minLatency)
+	}
+	bindingLost := bound1 && !bound2
+	if diff.Same() && len(diff.EstRowsDrifts) == 0 && !regressed && !bindingLost {
+		return captureResult{}
+	}
+	r := newCompareResult(p1, p2, diff)
+	r.SQL, r.Digest, r.Schema = sql, q.digest(), q.Schema
+	r.OldPlan, r.NewPlan = plan.FormatExplainRows(r1), plan.FormatExplainRows(r2)
+	r.Runtime, r.LatencyRegressed = runtime, regressed
+	r.OldBinding, r.NewBinding, r.BindingLost = bound1, bound2, bindingLost
+	r.OldPlanExplained = oldPlanExplained
+	return captureResult{Result: &r}
+}
+
+// explainQuery returns the plan of the query and whether it's produced under a binding, queries with
+// parameters are run as prepared statements if --prepared-execute is set.
+func explainQuery(s *sessionHandler, q Query, opt *captureOpt) (plan.Plan, [][]string, bool, error) {
+	if len(q.Params) > 0 && opt.preparedExecute {
+		return s.explainPrepared(strings.TrimSpace(q.SQL[len("explain"):]), q.Params)
+	}
+	explainSQL, jsonFormat := q.explainSQL(), opt.jsonFormat
+	if opt.analyze {
+		explainSQL, jsonFormat = "explain analyze"+explainSQL[len("explain"):], false
+	}
+	p, rows, err := explainPlan(s.tidbHandler, explainSQL, jsonFormat)
+	if err != nil {
+		return plan.Plan{}, nil, false, err
+	}
+	return p, rows, s.planFromBinding(), nil
+}
+
+// parseRecordedPlan parses the plan recorded by TiDB when the query ran.
+func parseRecordedPlan(version string, q Query) (plan.Plan, [][]string, error) {
+	header, rows, err := plan.SplitDecodedPlan(q.Plan)
+	if err != nil {
+		return plan.Plan{}, nil, err
+	}
+	p, err := plan.Parse(version, q.SQL, header, rows)
+	return p, rows, err
+}
+
+// explainPlan runs the explain statement and parses its result, EXPLAIN FORMAT='tidb_json'
+// is used if jsonFormat is true and the TiDB supports it.
+func explainPlan(h *tidbHandler, explainSQL string, jsonFormat bool) (plan.Plan, [][]string, error) {
+	if !jsonFormat || !plan.SupportJSONFormat(h.opt.version) {
+		header, rows, err := runExplain(h, explainSQL)
+		if err != nil {
+			return plan.Plan{}, nil, err
+		}
+		p, err := plan.Parse(h.opt.version, explainSQL, header, rows)
+		return p, rows, err
+	}
+
+	jsonSQL := "explain format='tidb_json'" + explainSQL[len("explain"):]
+	_, rows, err := runExplain(h, jsonSQL)
+	if err != nil {
+		return plan.Plan{}, nil, err
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return plan.Plan{}, nil, fmt.Errorf("empty result of %v", jsonSQL)
+	}
+	p, err := plan.ParseJSON(h.opt.version, explainSQL, rows[0][0])
+	if err != nil {
+		return plan.Plan{}, nil, err
+	}
+	_, rows, err = plan.JSONToExplainRows(rows[0][0])
+	return p, rows, err
+}
+
+// runExplain returns the column names and rows of the explain result.
+func runExplain(h *tidbHandler, explainSQL string) ([]string, [][]string, error) {
 	rows, err := h.db.Query(explainSQL)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
-	types, err := rows.ColumnTypes()
+	header, err := rows.Columns()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	nCols := len(types)
+	nCols := len(header)
 	results := make([][]string, 0, 8)
 	for rows.Next() {
 		cols := make([]string, nCols)
@@ -254,11 +454,11 @@ func runExplain(h *tidbHandler, explainSQL string) ([][]string, error) {
 			ptrs[i] = &cols[i]
 		}
 		if err := rows.Scan(ptrs...); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		results = append(results, cols)
 	}
-	return results, nil
+	return header, results, rows.Err()
 }
 
 func scanQueryFile(filepath string) ([]Query, error) {
@@ -290,6 +490,27 @@ func scanQueryFile(filepath string) ([]Query, error) {
 	return filtered, nil
 }
 
+// sqlDigest returns the digest of the SQL without the explain prefix, which is the same as digests recorded by TiDB.
+func sqlDigest(sql string) string {
+	sql = strings.TrimSpace(sql)
+	for _, prefix := range []string{"explain analyze ", "explain "} {
+		if matchPrefixCaseInsensitive(sql, prefix) {
+			sql = sql[len(prefix):]
+			break
+		}
+	}
+	_, digest := parser.NormalizeDigest(sql)
+	return digest
+}
+
+// digest returns the digest recorded by TiDB if it's exported with the query.
+func (q Query) digest() string {
+	if q.Digest != "" {
+		return q.Digest
+	}
+	return sqlDigest(q.SQL)
+}
+
 func matchPrefixCaseInsensitive(sql, prefix string) bool {
 	if len(sql) < len(prefix) {
 		return false
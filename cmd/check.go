@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"fmt"
 	"github.com/qw4990/plan-change-capturer/plan"
 	"github.com/spf13/cobra"
 	"io/ioutil"
@@ -12,6 +11,8 @@ type checkOpt struct {
 	filepath string
 	ver1     string
 	ver2     string
+	compare  compareOpt
+	report   reportOpt
 }
 
 func newCheckCmd() *cobra.Command {
@@ -21,12 +22,22 @@ func newCheckCmd() *cobra.Command {
 		Short: "check some plans manually",
 		Long:  `check some plans manually`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opt.report.check(); err != nil {
+				return err
+			}
+			compareOpts, err := opt.compare.options()
+			if err != nil {
+				return err
+			}
 			content, err := ioutil.ReadFile(opt.filepath)
 			if err != nil {
 				return err
 			}
 			lines := strings.Split(string(content), "\n")
 
+			var results []SinglePlanCompareResult
+			var unchanged []UnchangedQuery
+			checked := 0
 			var sql, plan1, plan2 string
 			beginLine, lineCount := -1, 0
 			for i, line := range lines {
@@ -49,9 +60,16 @@ func newCheckCmd() *cobra.Command {
 							plan1 = strings.Join(lines[beginLine:i+1], "\n")
 						} else {
 							plan2 = strings.Join(lines[beginLine:i+1], "\n")
-							if err := check(sql, opt.ver1, plan1, opt.ver2, plan2); err != nil {
+							r, err := check(sql, opt.ver1, plan1, opt.ver2, plan2, compareOpts...)
+							if err != nil {
 								return err
 							}
+							checked++
+							if r != nil {
+								results = append(results, *r)
+							} else {
+								unchanged = append(unchanged, UnchangedQuery{SQL: sql})
+							}
 							plan1 = ""
 							plan2 = ""
 							sql = ""
@@ -60,35 +78,35 @@ func newCheckCmd() *cobra.Command {
 					}
 				}
 			}
-			return nil
+			result := PlanCompareResult{NewVersion: opt.ver2, Summary: summarizeResults(results), Results: results, Unchanged: unchanged}
+			result.Summary.Total = checked
+			return writeReport(opt.report, result)
 		},
 	}
 	cmd.Flags().StringVar(&opt.ver1, "ver1", plan.V3, "TiDB version1")
 	cmd.Flags().StringVar(&opt.ver2, "ver2", plan.V4, "TiDB version2")
 	cmd.Flags().StringVar(&opt.filepath, "path", "", "File Path")
+	opt.compare.addFlags(cmd)
+	opt.report.addFlags(cmd)
 	return cmd
 }
 
-func check(sql, v1, p1, v2, p2 string) error {
+// check compares two plans of the SQL, it returns nil if they are the same.
+func check(sql, v1, p1, v2, p2 string, opts ...plan.CompareOption) (*SinglePlanCompareResult, error) {
 	plan1, err := plan.ParseText(sql, p1, v1)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	plan2, err := plan.ParseText(sql, p2, v2)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	reas, same := plan.Compare(plan1, plan2)
-	if !same {
-		fmt.Println("==============================================================================")
-		fmt.Println("SQL: ", sql)
-		fmt.Println("Plan1: ")
-		fmt.Println(p1)
-		fmt.Println("Plan2: ")
-		fmt.Println(p2)
-		fmt.Println(" Reason: ", reas)
+	if diff := plan.CompareDetailed(plan1, plan2, opts...); !diff.Same() || len(diff.EstRowsDrifts) > 0 {
+		r := newCompareResult(plan1, plan2, diff)
+		r.SQL, r.OldPlan, r.NewPlan = sql, p1, p2
+		return &r, nil
 	}
-	return nil
+	return nil, nil
 }
 
 func isPlanBoundary(line string) bool {
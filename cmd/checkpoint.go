@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// checkpointOpt contains options about the checkpoint file of a long run.
+type checkpointOpt struct {
+	path   string
+	resume bool
+}
+
+func (opt *checkpointOpt) addFlags(cmd *cobra.Command, defaultPath string) {
+	cmd.Flags().StringVar(&opt.path, "checkpoint-file", defaultPath, "file to persist the progress, it's removed after the run finishes")
+	cmd.Flags().BoolVar(&opt.resume, "resume", false, "continue from the checkpoint file of the last unfinished run")
+}
+
+// checkpointInterval is the min interval between two writes of the checkpoint file.
+var checkpointInterval = 5 * time.Second
+
+// checkpoint records results of processed queries, a result is reused only if
+// the query at the same index is unchanged.
+type checkpoint struct {
+	Entries   map[int]checkpointEntry `json:"entries"`
+	LastIndex int                     `json:"lastIndex"`
+
+	path      string
+	mu        sync.Mutex
+	lastWrite time.Time
+}
+
+type checkpointEntry struct {
+	Key    string          `json:"key"`
+	Result json.RawMessage `json:"result"`
+}
+
+// checkpointKey identifies a query by its schema and SQL.
+func checkpointKey(schema, sql string) string {
+	h := sha256.Sum256([]byte(schema + "\n" + sql))
+	return hex.EncodeToString(h[:])
+}
+
+// openCheckpoint loads the checkpoint file if resume is set, otherwise starts a new one.
+func openCheckpoint(opt checkpointOpt, out io.Writer) (*checkpoint, error) {
+	cp := &checkpoint{Entries: make(map[int]checkpointEntry), LastIndex: -1, path: opt.path}
+	if !opt.resume {
+		return cp, nil
+	}
+	if opt.path == "" {
+		return nil, fmt.Errorf("no checkpoint file to resume from")
+	}
+	data, err := ioutil.ReadFile(opt.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintf(out, "[PCC] checkpoint file %v doesn't exist, start from the beginning\n", opt.path)
+			return cp, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("read checkpoint file %v error: %v", opt.path, err)
+	}
+	if cp.Entries == nil {
+		cp.Entries = make(map[int]checkpointEntry)
+	}
+	fmt.Fprintf(out, "[PCC] resume from checkpoint file %v, %v queries have been processed\n", opt.path, len(cp.Entries))
+	return cp, nil
+}
+
+// get unmarshals the result of the i-th query into v, it returns false if the query hasn't been processed.
+func (cp *checkpoint) get(i int, key string, v interface{}) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	e, ok := cp.Entries[i]
+	if !ok || e.Key != key {
+		return false
+	}
+	return json.Unmarshal(e.Result, v) == nil
+}
+
+// put records the result of the i-th query and writes the checkpoint file periodically.
+func (cp *checkpoint) put(i int, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.Entries[i] = checkpointEntry{Key: key, Result: data}
+	if i > cp.LastIndex {
+		cp.LastIndex = i
+	}
+	if time.Since(cp.lastWrite) < checkpointInterval {
+		return nil
+	}
+	return cp.writeLocked()
+}
+
+// flush writes all recorded results into the checkpoint file.
+func (cp *checkpoint) flush() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.writeLocked()
+}
+
+func (cp *checkpoint) writeLocked() error {
+	cp.lastWrite = time.Now()
+	if cp.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	// write into a temporary file first to avoid breaking the checkpoint if the process is killed
+	tmp := cp.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
+}
+
+// remove deletes the checkpoint file after the run finishes.
+func (cp *checkpoint) remove() error {
+	if cp.path == "" {
+		return nil
+	}
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+	"github.com/spf13/cobra"
+)
+
+// compareOpt contains the options about how plans are compared, which are
+// shared by capture, check and load-and-compare.
+type compareOpt struct {
+	rules             []string
+	noDefaultRules    bool
+	rulesFile         string
+	estRowsDriftRatio float64
+	// smallEstRows and largeEstRows are the thresholds to classify the severity of differences.
+	smallEstRows float64
+	largeEstRows float64
+}
+
+// compareConfig is the content of the file specified by --rules-file, for example:
+//
+//	{"rules": ["agg_equivalence"], "noDefaultRules": false, "estRowsDriftRatio": 10}
+type compareConfig struct {
+	Rules             []string `json:"rules"`
+	NoDefaultRules    bool     `json:"noDefaultRules"`
+	EstRowsDriftRatio float64  `json:"estRowsDriftRatio"`
+}
+
+func (opt *compareOpt) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&opt.rules, "rules", nil,
+		fmt.Sprintf("rules used to compare plans in addition to the default rules, available rules: %v", strings.Join(plan.RuleNames(), ", ")))
+	cmd.Flags().BoolVar(&opt.noDefaultRules, "no-default-rules", false,
+		fmt.Sprintf("do not use the default rules (%v), only rules in --rules and --rules-file are used", strings.Join(defaultRuleNames(), ", ")))
+	cmd.Flags().StringVar(&opt.rulesFile, "rules-file", "", "a JSON file containing the rules used to compare plans")
+	cmd.Flags().Float64Var(&opt.estRowsDriftRatio, "est-rows-drift-ratio", 0,
+		"report operators whose estimated rows change by more than this ratio, 0 means disabled")
+	cmd.Flags().Float64Var(&opt.smallEstRows, "small-est-rows", plan.DefaultSmallEstRows,
+		"differences of operators with fewer estimated rows are of the info severity")
+	cmd.Flags().Float64Var(&opt.largeEstRows, "large-est-rows", plan.DefaultLargeEstRows,
+		"new full scans or lost index joins of operators with more estimated rows are of the critical severity")
+}
+
+// options returns the compare options, rules in the file and the flag are added to the default rules,
+// and the drift ratio in the flag overrides the one in the file.
+func (opt *compareOpt) options() ([]plan.CompareOption, error) {
+	names := opt.rules
+	noDefault := opt.noDefaultRules
+	driftRatio := opt.estRowsDriftRatio
+	if opt.rulesFile != "" {
+		data, err := ioutil.ReadFile(opt.rulesFile)
+		if err != nil {
+			return nil, err
+		}
+		var cfg compareConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("read %v error: %v", opt.rulesFile, err)
+		}
+		names = append(cfg.Rules, names...)
+		noDefault = noDefault || cfg.NoDefaultRules
+		if driftRatio == 0 {
+			driftRatio = cfg.EstRowsDriftRatio
+		}
+	}
+	var opts []plan.CompareOption
+	if len(names) > 0 || noDefault {
+		selected, err := plan.RulesByName(names)
+		if err != nil {
+			return nil, err
+		}
+		var rules []plan.Rule
+		if !noDefault {
+			rules = append(rules, plan.DefaultRules...)
+		}
+		for _, r := range selected {
+			if !containsRule(rules, r) {
+				rules = append(rules, r)
+			}
+		}
+		opts = append(opts, plan.WithRules(rules...))
+	}
+	if driftRatio > 0 {
+		opts = append(opts, plan.WithEstRowsDrift(driftRatio))
+	}
+	// zero thresholds mean the defaults
+	small, large := opt.smallEstRows, opt.largeEstRows
+	if small == 0 {
+		small = plan.DefaultSmallEstRows
+	}
+	if large == 0 {
+		large = plan.DefaultLargeEstRows
+	}
+	if small > large {
+		return nil, fmt.Errorf("--small-est-rows %v should not be larger than --large-est-rows %v", small, large)
+	}
+	if small != plan.DefaultSmallEstRows || large != plan.DefaultLargeEstRows {
+		opts = append(opts, plan.WithSeverityThresholds(small, large))
+	}
+	return opts, nil
+}
+
+func containsRule(rules []plan.Rule, r plan.Rule) bool {
+	for _, rule := range rules {
+		if rule.Name() == r.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultRuleNames() []string {
+	names := make([]string, 0, len(plan.DefaultRules))
+	for _, r := range plan.DefaultRules {
+		names = append(names, r.Name())
+	}
+	return names
+}
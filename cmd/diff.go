@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+	"github.com/spf13/cobra"
+)
+
+type diffOpt struct {
+	ver1    string
+	ver2    string
+	compare compareOpt
+	report  reportOpt
+}
+
+func newDiffCmd() *cobra.Command {
+	var opt diffOpt
+	cmd := &cobra.Command{
+		Use:   "diff <file1> <file2>",
+		Short: "compare plans in two result or baseline files offline",
+		Long: `compare plans in two files without any TiDB, queries are matched by their schemas and digests.
+A file can be the JSON result of capture or load-and-compare written with --with-plan-trees, a JSON array of plans,
+or a baseline store directory. The new plans in result files are compared, capture results only contain changed queries.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opt.report.check(); err != nil {
+				return err
+			}
+			logOut := opt.report.logOut()
+			compareOpts, err := opt.compare.options()
+			if err != nil {
+				return err
+			}
+			plans1, ver1, err := loadPlanFile(logOut, args[0], opt.ver1)
+			if err != nil {
+				return err
+			}
+			plans2, ver2, err := loadPlanFile(logOut, args[1], opt.ver2)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(logOut, "[PCC] compare %v plans of %v with %v plans of %v\n", len(plans1), ver1, len(plans2), ver2)
+			return writeReport(opt.report, diffPlans(logOut, plans1, plans2, ver2, compareOpts...))
+		},
+	}
+	cmd.Flags().StringVar(&opt.ver1, "ver1", "", "version of plans to read from the first baseline store, the latest plans are used if empty")
+	cmd.Flags().StringVar(&opt.ver2, "ver2", "", "version of plans to read from the second baseline store, the latest plans are used if empty")
+	opt.compare.addFlags(cmd)
+	opt.report.addFlags(cmd)
+	return cmd
+}
+
+// loadPlanFile reads plans and their version from a result file, a plan file or a baseline store directory.
+func loadPlanFile(out io.Writer, path, version string) ([]plan.Plan, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.IsDir() {
+		return loadBaselinePlans(path, version)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var plans []plan.Plan
+		if err := json.Unmarshal(data, &plans); err != nil {
+			return nil, "", fmt.Errorf("read plans in %v error: %v", path, err)
+		}
+		if len(plans) > 0 {
+			version = string(plans[0].Ver)
+		}
+		return plans, version, nil
+	}
+
+	var result PlanCompareResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, "", fmt.Errorf("read results in %v error: %v", path, err)
+	}
+	var plans []plan.Plan
+	skipped := 0
+	for _, r := range result.Results {
+		p, planText := r.NewPlanTree, r.NewPlan
+		if p == nil {
+			// result files of older versions omit the new plan if it's the same as the old one
+			p, planText = r.OldPlanTree, r.OldPlan
+		}
+		if p == nil {
+			skipped++
+			continue
+		}
+		p.Schema = r.Schema
+		if p.PlanText == "" {
+			p.PlanText = planText
+		}
+		plans = append(plans, *p)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(out, "[PCC] %v results in %v have no parsed plans and are skipped, write results with --with-plan-trees to keep them\n", skipped, path)
+	}
+	return plans, result.NewVersion, nil
+}
+
+func loadBaselinePlans(dir, version string) ([]plan.Plan, string, error) {
+	store := &baselineStore{dir: dir}
+	records, err := store.records()
+	if err != nil {
+		return nil, "", err
+	}
+	var plans []plan.Plan
+	for _, r := range records {
+		e, ok := r.latest(version)
+		if !ok {
+			continue
+		}
+		p, err := r.plan(e)
+		if err != nil {
+			return nil, "", fmt.Errorf("parse plan of %v in %v error: %v", r.SQL, dir, err)
+		}
+		plans = append(plans, p)
+	}
+	if version == "" {
+		version = "latest"
+	}
+	return plans, version, nil
+}
+
+// planDigest returns the digest of the SQL without the explain prefix.
+func planDigest(p plan.Plan) string {
+	return sqlDigest(p.SQL)
+}
+
+// planKey identifies a query by its schema and digest.
+func planKey(p plan.Plan) string {
+	return strings.ToLower(p.Schema) + "." + planDigest(p)
+}
+
+// diffPlans compares plans of the same queries, queries only in one side are ignored.
+func diffPlans(out io.Writer, plans1, plans2 []plan.Plan, ver2 string, opts ...plan.CompareOption) PlanCompareResult {
+	byKey := make(map[string]plan.Plan, len(plans2))
+	for _, p := range plans2 {
+		if _, ok := byKey[planKey(p)]; !ok {
+			byKey[planKey(p)] = p
+		}
+	}
+	matched := make(map[string]struct{}, len(plans1))
+	var changes []SinglePlanCompareResult
+	var unchanged []UnchangedQuery
+	notFound := 0
+	for _, p1 := range plans1 {
+		key := planKey(p1)
+		p2, ok := byKey[key]
+		if !ok {
+			notFound++
+			continue
+		}
+		if _, ok := matched[key]; ok {
+			continue
+		}
+		matched[key] = struct{}{}
+		// SQLs of the same digest may have different texts like the explain prefix or literals
+		p2.SQL = p1.SQL
+		diff := plan.CompareDetailed(p1, p2, opts...)
+		if diff.Same() && len(diff.EstRowsDrifts) == 0 {
+			unchanged = append(unchanged, UnchangedQuery{SQL: p1.SQL, Digest: planDigest(p1), Schema: p1.Schema})
+			continue
+		}
+		r := newCompareResult(p1, p2, diff)
+		r.SQL, r.Digest, r.Schema = p1.SQL, planDigest(p1), p1.Schema
+		r.OldPlan, r.NewPlan = p1.PlanText, p2.PlanText
+		r.NewVersion = ver2
+		changes = append(changes, r)
+	}
+	if notFound > 0 {
+		fmt.Fprintf(out, "[PCC] %v queries in the first file are not found in the second one\n", notFound)
+	}
+	result := PlanCompareResult{NewVersion: ver2, Summary: summarizeResults(changes), Results: changes, Unchanged: unchanged}
+	result.Summary.Total = len(matched)
+	return result
+}
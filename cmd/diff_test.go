@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+func (s *loadTestSuite) TestDiffPlanFiles(c *C) {
+	dir := c.MkDir()
+
+	// a result file whose new plan is a table scan
+	result := reportTestResult(c)
+	result.Results[0].Schema = "test"
+	resultPath := filepath.Join(dir, "result.json")
+	c.Assert(writeReport(reportOpt{format: "json", file: resultPath, planTrees: true}, result), IsNil)
+	// parsed plans are not written by default
+	noTreePath := filepath.Join(dir, "result-no-tree.json")
+	c.Assert(writeReport(reportOpt{format: "json", file: noTreePath}, result), IsNil)
+	c.Assert(result.Results[0].NewPlanTree, NotNil)
+	noTreePlans, _, err := loadPlanFile(ioutil.Discard, noTreePath, "")
+	c.Assert(err, IsNil)
+	c.Assert(noTreePlans, HasLen, 0)
+
+	// a plan file with the same query using an index
+	p, err := plan.ParseText("select * from t where b = 20", reportTestPlan1, plan.V4)
+	c.Assert(err, IsNil)
+	p.Schema = "TEST"
+	other, err := plan.ParseText("select * from t2", reportTestPlan1, plan.V4)
+	c.Assert(err, IsNil)
+	data, err := json.Marshal([]plan.Plan{p, other})
+	c.Assert(err, IsNil)
+	planPath := filepath.Join(dir, "plans.json")
+	c.Assert(ioutil.WriteFile(planPath, data, 0644), IsNil)
+
+	plans1, ver1, err := loadPlanFile(ioutil.Discard, planPath, "")
+	c.Assert(err, IsNil)
+	c.Assert(ver1, Equals, plan.V4)
+	c.Assert(plans1, HasLen, 2)
+	plans2, ver2, err := loadPlanFile(ioutil.Discard, resultPath, "")
+	c.Assert(err, IsNil)
+	c.Assert(ver2, Equals, plan.V4)
+	c.Assert(plans2, HasLen, 1)
+	c.Assert(plans2[0].Root.ID(), Equals, "TableReader_7")
+
+	diff := diffPlans(ioutil.Discard, plans1, plans2, ver2)
+	c.Assert(diff.Summary.Total, Equals, 1)
+	c.Assert(diff.Results, HasLen, 1)
+	c.Assert(diff.Results[0].Reason, Equals, "different operators IndexLookUp_10 and TableReader_7")
+	c.Assert(diff.Results[0].Digest, Equals, planDigest(plans2[0]))
+	c.Assert(diffPlans(ioutil.Discard, plans1, plans1, ver1).Results, HasLen, 0)
+
+	// plans in a baseline store
+	storeDir := filepath.Join(dir, "baseline")
+	store, err := openBaselineStore(storeDir)
+	c.Assert(err, IsNil)
+	e := baselineEntry{
+		Version: "v5.0.0",
+		Date:    time.Now(),
+		Header:  []string{"id", "estRows", "task", "access object", "operator info"},
+		Rows:    [][]string{{"TableReader_7", "10.00", "root", "", ""}, {"└─TableFullScan_5", "10000.00", "cop[tikv]", "table:t", ""}},
+	}
+	c.Assert(store.save("test", "explain select * from t where b=30", "d", e), IsNil)
+	plans3, ver3, err := loadPlanFile(ioutil.Discard, storeDir, "")
+	c.Assert(err, IsNil)
+	c.Assert(ver3, Equals, "latest")
+	c.Assert(plans3, HasLen, 1)
+	c.Assert(diffPlans(ioutil.Discard, plans1, plans3, ver3).Results, HasLen, 1)
+	plans3, _, err = loadPlanFile(ioutil.Discard, storeDir, "v4.0.0")
+	c.Assert(err, IsNil)
+	c.Assert(plans3, HasLen, 0)
+
+	_, _, err = loadPlanFile(ioutil.Discard, filepath.Join(dir, "not-exist.json"), "")
+	c.Assert(err, NotNil)
+}
@@ -8,7 +8,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/spf13/cobra"
@@ -21,6 +23,13 @@ type exportOpt struct {
 	dir       string
 	tables    []string
 	specDB    string
+
+	slowLogFiles    []string
+	generalLogFiles []string
+	pcapFiles       []string
+	pcapPort        int
+
+	stmtSummary stmtSummaryFilter
 }
 
 func newExportCmd() *cobra.Command {
@@ -36,13 +45,19 @@ func newExportCmd() *cobra.Command {
 				return runExportSchemaStats(&opt)
 			case "stmt_summary":
 				return runExportStmtSummary(&opt)
+			case "slow_log":
+				return runExportSlowLog(&opt)
+			case "general_log":
+				return runExportGeneralLog(&opt)
+			case "pcap":
+				return runExportPcap(&opt)
 			default:
 				return fmt.Errorf("unknonw export mode %v", opt.mode)
 			}
 
 		},
 	}
-	cmd.Flags().StringVar(&opt.mode, "mode", "", "schema_stats: export schema and stats from TiDB; stmt_summary: export queries from the statement_summary table (schema_stats / stmt_summary)")
+	cmd.Flags().StringVar(&opt.mode, "mode", "", "schema_stats: export schema and stats from TiDB; stmt_summary: export queries from the statement_summary table; slow_log: export queries from slow query log files; general_log: export queries from general log files; pcap: export queries from packet captures of the TiDB port (schema_stats / stmt_summary / slow_log / general_log / pcap)")
 	cmd.Flags().StringVar(&opt.db.addr, "addr", "127.0.0.1", "address of the target TiDB")
 	cmd.Flags().StringVar(&opt.db.port, "port", "4000", "port of the target TiDB")
 	cmd.Flags().StringVar(&opt.db.statusPort, "status-port", "10080", "status port of the target TiDB")
@@ -51,7 +66,17 @@ func newExportCmd() *cobra.Command {
 	cmd.Flags().StringVar(&opt.dir, "schema-stats-dir", "", "destination directory to store exported schemas and statistics (only for schema_stats mode)")
 	cmd.Flags().StringVar(&opt.specDB, "db", "", "DB to export, only export schema/stats of tables in this DB")
 	cmd.Flags().StringSliceVar(&opt.tables, "tables", nil, "tables to export, if nil export all tables' schema and stats (only for schema_stats mode)")
-	cmd.Flags().StringVar(&opt.queryFile, "query-file", "", "file path to store queries (only for stmt_summary mode)")
+	cmd.Flags().StringVar(&opt.queryFile, "query-file", "", "file path to store queries (only for stmt_summary, slow_log, general_log and pcap mode)")
+	cmd.Flags().StringSliceVar(&opt.slowLogFiles, "slow-log-files", nil, "TiDB slow query log files to read queries from (only for slow_log mode)")
+	cmd.Flags().StringSliceVar(&opt.generalLogFiles, "general-log-files", nil, "TiDB log files containing the general log to read queries from (only for general_log mode)")
+	cmd.Flags().StringSliceVar(&opt.pcapFiles, "pcap-files", nil, "pcap or pcapng files capturing the MySQL traffic of TiDB (only for pcap mode)")
+	cmd.Flags().IntVar(&opt.pcapPort, "pcap-port", 4000, "TiDB port in the pcap files (only for pcap mode)")
+	cmd.Flags().Int64Var(&opt.stmtSummary.minExecCount, "min-exec-count", 0, "only export queries executed at least this many times (only for stmt_summary mode)")
+	cmd.Flags().IntVar(&opt.stmtSummary.topNByLatency, "top-n-by-latency", 0, "only export N queries with the largest total latency, 0 means no limit (only for stmt_summary mode)")
+	cmd.Flags().StringVar(&opt.stmtSummary.startTime, "start-time", "", "only export queries executed after this time like '2006-01-02 15:04:05' (only for stmt_summary mode)")
+	cmd.Flags().StringVar(&opt.stmtSummary.endTime, "end-time", "", "only export queries executed before this time like '2006-01-02 15:04:05' (only for stmt_summary mode)")
+	cmd.Flags().StringSliceVar(&opt.stmtSummary.digests, "digests", nil, "only export queries with these digests (only for stmt_summary mode)")
+	cmd.Flags().StringSliceVar(&opt.stmtSummary.excludeDigests, "exclude-digests", nil, "do not export queries with these digests (only for stmt_summary mode)")
 	cmd.Flags().BoolVar(&opt.db.tls, "tls", false, "cluster enable tls")
 	cmd.Flags().StringVar(&opt.db.cacert, "cacert", "", "CA certificate to verify peer against (SSL)")
 	cmd.Flags().StringVar(&opt.db.cert, "cert", "", "Client certificate file and password (SSL)")
@@ -76,46 +101,174 @@ func runExportStmtSummary(opt *exportOpt) error {
 		return fmt.Errorf("no file path to store queries")
 	}
 
-	return exportQueriesFromStmtSummary(db, opt.specDB, opt.queryFile)
+	opt.stmtSummary.specDB = opt.specDB
+	if err := opt.stmtSummary.check(); err != nil {
+		return err
+	}
+	return exportQueriesFromStmtSummary(db, opt.stmtSummary, opt.queryFile)
+}
+
+// stmtSummaryFilter selects queries in the statement summary.
+type stmtSummaryFilter struct {
+	specDB         string
+	minExecCount   int64
+	topNByLatency  int
+	startTime      string
+	endTime        string
+	digests        []string
+	excludeDigests []string
+}
+
+const stmtSummaryTimeLayout = "2006-01-02 15:04:05"
+
+func (f stmtSummaryFilter) check() error {
+	for _, t := range []string{f.startTime, f.endTime} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse(stmtSummaryTimeLayout, t); err != nil {
+			return fmt.Errorf("invalid time %v, the format should be like '%v'", t, stmtSummaryTimeLayout)
+		}
+	}
+	return nil
+}
+
+// sql returns the statement to select plans of queries from the statement summary history and its arguments,
+// records of a plan in different summary windows and instances are merged.
+func (f stmtSummaryFilter) sql() (string, []interface{}) {
+	conds := []string{"STMT_TYPE = 'Select'", "SCHEMA_NAME != 'NULL'"}
+	var args []interface{}
+	if f.specDB != "" {
+		conds = append(conds, "SCHEMA_NAME = ?")
+		args = append(args, f.specDB)
+	}
+	// windows overlapping the time range are selected
+	if f.startTime != "" {
+		conds = append(conds, "SUMMARY_END_TIME > ?")
+		args = append(args, f.startTime)
+	}
+	if f.endTime != "" {
+		conds = append(conds, "SUMMARY_BEGIN_TIME < ?")
+		args = append(args, f.endTime)
+	}
+	inList := func(digests []string) string {
+		marks := make([]string, 0, len(digests))
+		for _, d := range digests {
+			marks = append(marks, "?")
+			args = append(args, d)
+		}
+		return "(" + strings.Join(marks, ", ") + ")"
+	}
+	if len(f.digests) > 0 {
+		conds = append(conds, "DIGEST IN "+inList(f.digests))
+	}
+	if len(f.excludeDigests) > 0 {
+		conds = append(conds, "DIGEST NOT IN "+inList(f.excludeDigests))
+	}
+
+	query := `SELECT SCHEMA_NAME, DIGEST, IFNULL(PLAN_DIGEST, ''), MAX(QUERY_SAMPLE_TEXT), MAX(IFNULL(PLAN, '')),
+SUM(EXEC_COUNT), SUM(SUM_LATENCY), MAX(MAX_LATENCY), MAX(IFNULL(PLAN_IN_BINDING, 0))
+FROM information_schema.cluster_statements_summary_history
+WHERE ` + strings.Join(conds, " AND ") + `
+GROUP BY SCHEMA_NAME, DIGEST, PLAN_DIGEST`
+	return query, args
+}
+
+// stmtSummaryPlan is the summary of a plan of a query.
+type stmtSummaryPlan struct {
+	Query
+	sumLatency int64
 }
 
-func exportQueriesFromStmtSummary(db *tidbHandler, specDB, dstFile string) error {
-	query := `SELECT SCHEMA_NAME, QUERY_SAMPLE_TEXT FROM information_schema.cluster_statements_summary_history WHERE lower(QUERY_SAMPLE_TEXT) LIKE '%select%' and SCHEMA_NAME != 'NULL'`
-	if specDB != "" {
-		query = `SELECT SCHEMA_NAME, QUERY_SAMPLE_TEXT FROM information_schema.cluster_statements_summary_history WHERE SCHEMA_NAME != 'NULL' and lower(QUERY_SAMPLE_TEXT) LIKE '%select%' AND SCHEMA_NAME='` + specDB + `'`
+// merge merges plans of the same query into one query, whose sample and plan are of the most executed plan,
+// and queries with the largest total latency are returned first.
+func (f stmtSummaryFilter) merge(plans []stmtSummaryPlan) []Query {
+	type digestSummary struct {
+		sample     stmtSummaryPlan
+		execCount  int64
+		sumLatency int64
+		maxLatency time.Duration
+	}
+	var summaries []*digestSummary
+	byDigest := make(map[string]*digestSummary, len(plans))
+	for _, p := range plans {
+		key := strings.ToLower(p.Schema) + "." + p.Digest
+		s, ok := byDigest[key]
+		if !ok {
+			s = &digestSummary{sample: p}
+			byDigest[key] = s
+			summaries = append(summaries, s)
+		} else if p.ExecCount > s.sample.ExecCount {
+			s.sample = p
+		}
+		s.execCount += p.ExecCount
+		s.sumLatency += p.sumLatency
+		if p.MaxLatency > s.maxLatency {
+			s.maxLatency = p.MaxLatency
+		}
 	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].sumLatency > summaries[j].sumLatency
+	})
 
-	rows, err := db.db.Query(query)
+	qs := make([]Query, 0, len(summaries))
+	for _, s := range summaries {
+		if s.execCount < f.minExecCount {
+			continue
+		}
+		if f.topNByLatency > 0 && len(qs) >= f.topNByLatency {
+			break
+		}
+		q := s.sample.Query
+		q.ExecCount, q.MaxLatency = s.execCount, s.maxLatency
+		if s.execCount > 0 {
+			q.AvgLatency = time.Duration(s.sumLatency / s.execCount)
+		}
+		qs = append(qs, q)
+	}
+	return qs
+}
+
+func exportQueriesFromStmtSummary(db *tidbHandler, filter stmtSummaryFilter, dstFile string) error {
+	query, args := filter.sql()
+	rows, err := db.db.Query(query, args...)
 	if err != nil {
 		return fmt.Errorf("select queries from information_schema.cluster_statements_summary_history error: %v", err)
 	}
 	defer rows.Close()
-	var qs []Query
+	var plans []stmtSummaryPlan
 	for rows.Next() {
-		var schema, query string
-		if err := rows.Scan(&schema, &query); err != nil {
+		var p stmtSummaryPlan
+		var sampleText string
+		var maxLatency int64
+		if err := rows.Scan(&p.Schema, &p.Digest, &p.PlanDigest, &sampleText, &p.Plan, &p.ExecCount, &p.sumLatency, &maxLatency, &p.PlanFromBinding); err != nil {
 			return fmt.Errorf("scan result error: %v", err)
 		}
-		qs = append(qs, Query{
-			Schema: schema,
-			SQL:    query,
-		})
+		if p.SQL, p.Params, err = splitPreparedSQL(sampleText); err != nil {
+			return err
+		}
+		p.MaxLatency = time.Duration(maxLatency)
+		plans = append(plans, p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("scan result error: %v", err)
 	}
 
-	file, err := os.OpenFile(dstFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
-	if err != nil {
-		return err
+	qs := filter.merge(plans)
+	if err := writeQueryFile(dstFile, qs); err != nil {
+		return fmt.Errorf("export queries error: %v", err)
 	}
-	defer file.Close()
+	fmt.Printf("export %v queries from statement_summary into %v successfully\n", len(qs), dstFile)
+	return nil
+}
+
+// writeQueryFile writes queries into the file in the format read by scanQueryFile.
+func writeQueryFile(dstFile string, qs []Query) error {
 	jdata, err := json.Marshal(qs)
 	if err != nil {
-		return fmt.Errorf("export queries error: %v", err)
-	}
-	if err := ioutil.WriteFile(dstFile, jdata, 0666); err != nil {
-		return fmt.Errorf("export queries error: %v", err)
+		return err
 	}
-	fmt.Printf("export queries from statement_summary into %v successfully\n", dstFile)
-	return nil
+	return ioutil.WriteFile(dstFile, jdata, 0666)
 }
 
 func runExportSchemaStats(opt *exportOpt) error {
@@ -179,6 +332,10 @@ func exportSchemaStats(db *tidbHandler, dir, specDB string, tablesWhiteList []st
 			}
 		}
 	}
+	if err := exportBindings(db, dir, specDB); err != nil {
+		// old versions of TiDB don't support bindings
+		fmt.Printf("[PCC]: export global bindings error: %v, skip it\n", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+func (s *loadTestSuite) TestStmtSummaryFilter(c *C) {
+	query, args := stmtSummaryFilter{}.sql()
+	c.Assert(strings.Contains(query, "WHERE STMT_TYPE = 'Select' AND SCHEMA_NAME != 'NULL'\nGROUP BY"), IsTrue)
+	c.Assert(strings.HasSuffix(query, "GROUP BY SCHEMA_NAME, DIGEST, PLAN_DIGEST"), IsTrue)
+	c.Assert(args, IsNil)
+
+	f := stmtSummaryFilter{
+		specDB:         "test",
+		minExecCount:   10,
+		topNByLatency:  5,
+		startTime:      "2021-04-15 10:00:00",
+		endTime:        "2021-04-15 11:00:00",
+		digests:        []string{"d1", "d2"},
+		excludeDigests: []string{"d3"},
+	}
+	c.Assert(f.check(), IsNil)
+	query, args = f.sql()
+	c.Assert(strings.Contains(query, "WHERE STMT_TYPE = 'Select' AND SCHEMA_NAME != 'NULL' AND SCHEMA_NAME = ? AND SUMMARY_END_TIME > ? AND SUMMARY_BEGIN_TIME < ? AND DIGEST IN (?, ?) AND DIGEST NOT IN (?)\n"), IsTrue)
+	c.Assert(args, DeepEquals, []interface{}{"test", "2021-04-15 10:00:00", "2021-04-15 11:00:00", "d1", "d2", "d3"})
+
+	f.startTime = "2021/04/15"
+	c.Assert(f.check(), NotNil)
+}
+
+func (s *loadTestSuite) TestMergeStmtSummaryPlans(c *C) {
+	plan := func(digest, planDigest string, execCount, sumLatency int64, maxLatency time.Duration) stmtSummaryPlan {
+		q := Query{Schema: "test", SQL: "select " + planDigest, Digest: digest, PlanDigest: planDigest, ExecCount: execCount, MaxLatency: maxLatency}
+		return stmtSummaryPlan{Query: q, sumLatency: sumLatency}
+	}
+	plans := []stmtSummaryPlan{
+		plan("d1", "p1", 2, 200, 150),
+		plan("d2", "p3", 20, 2000, 300),
+		plan("d1", "p2", 8, 400, 100),
+		plan("d3", "p4", 1, 100, 100),
+	}
+
+	// plans of a query are merged into one, whose sample is of the most executed plan
+	qs := stmtSummaryFilter{}.merge(plans)
+	c.Assert(qs, HasLen, 3)
+	c.Assert(qs[0].Digest, Equals, "d2")
+	c.Assert(qs[1].Digest, Equals, "d1")
+	c.Assert(qs[1].PlanDigest, Equals, "p2")
+	c.Assert(qs[1].SQL, Equals, "select p2")
+	c.Assert(qs[1].ExecCount, Equals, int64(10))
+	c.Assert(qs[1].AvgLatency, Equals, time.Duration(60))
+	c.Assert(qs[1].MaxLatency, Equals, time.Duration(150))
+	c.Assert(qs[2].Digest, Equals, "d3")
+
+	qs = stmtSummaryFilter{minExecCount: 10, topNByLatency: 1}.merge(plans)
+	c.Assert(qs, HasLen, 1)
+	c.Assert(qs[0].Digest, Equals, "d2")
+	qs = stmtSummaryFilter{minExecCount: 10}.merge(plans)
+	c.Assert(qs, HasLen, 2)
+	c.Assert(qs[1].Digest, Equals, "d1")
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const generalLogTag = "GENERAL_LOG"
+
+func runExportGeneralLog(opt *exportOpt) error {
+	return exportQueriesFromFiles(opt, "general log", opt.generalLogFiles, func(r io.Reader) ([]Query, error) {
+		return parseGeneralLog(r, opt.specDB)
+	})
+}
+
+// parseGeneralLog reads SELECT queries and their current databases in the TiDB general log like:
+//
+//	[2021/04/15 10:00:00.000 +08:00] [INFO] [session.go:2418] [GENERAL_LOG] [conn=5] ... [current_db=test] ... [sql="select * from t"]
+func parseGeneralLog(r io.Reader, specDB string) ([]Query, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), slowLogMaxLineSize)
+	var qs []Query
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, generalLogTag) {
+			continue
+		}
+		fields := parseLogFields(line)
+		schema := fields["current_db"]
+		if specDB != "" && !strings.EqualFold(schema, specDB) {
+			continue
+		}
+		q, ok, err := newSelectQuery(schema, fields["sql"], "")
+		if err != nil {
+			return qs, err
+		}
+		if ok {
+			qs = append(qs, q)
+		}
+	}
+	return qs, scanner.Err()
+}
+
+// parseLogFields returns [key=value] fields in a TiDB log line, quoted values are unquoted.
+func parseLogFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for i := 0; i < len(line); i++ {
+		if line[i] != '[' {
+			continue
+		}
+		eq := strings.IndexAny(line[i+1:], "=]")
+		if eq < 0 || line[i+1+eq] != '=' {
+			continue
+		}
+		key := line[i+1 : i+1+eq]
+		j := i + 1 + eq + 1
+		if j < len(line) && line[j] == '"' {
+			// find the closing quote, escaped quotes are skipped
+			end := j + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				break
+			}
+			if v, err := strconv.Unquote(line[j : end+1]); err == nil {
+				fields[key] = v
+			}
+			i = end
+			continue
+		}
+		end := strings.IndexByte(line[j:], ']')
+		if end < 0 {
+			break
+		}
+		fields[key] = line[j : j+end]
+		i = j + end
+	}
+	return fields
+}
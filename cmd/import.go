@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"strings"
 
 	"github.com/go-sql-driver/mysql"
@@ -13,6 +15,8 @@ type importOpt struct {
 	db     tidbAccessOptions
 	dir    string
 	specDB string
+	// bindings is whether to import global bindings exported with schemas and statistics.
+	bindings bool
 }
 
 func newImportCmd() *cobra.Command {
@@ -27,7 +31,15 @@ func newImportCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("connect to DB error: %v", err)
 			}
-			return importSchemaStats(db, opt.specDB, opt.dir)
+			if err := importSchemaStats(db, opt.specDB, opt.dir, os.Stdout); err != nil {
+				return err
+			}
+			if opt.bindings {
+				if err := importBindings(db, opt.specDB, opt.dir, os.Stdout); err != nil {
+					return fmt.Errorf("import global bindings error: %v", err)
+				}
+			}
+			return nil
 		},
 	}
 	cmd.Flags().StringVar(&opt.db.addr, "addr", "127.0.0.1", "address of the target TiDB")
@@ -36,14 +48,15 @@ func newImportCmd() *cobra.Command {
 	cmd.Flags().StringVar(&opt.db.password, "password", "", "password to access the target TiDB")
 	cmd.Flags().StringVar(&opt.dir, "schema-stats-dir", "", "the directory which stores schemas and statistics")
 	cmd.Flags().StringVar(&opt.specDB, "db", "", "the DB to import, stats/schemas of other DBs will be ignored")
+	cmd.Flags().BoolVar(&opt.bindings, "bindings", true, "import global bindings stored in the schema-stats-dir")
 	return cmd
 }
 
-func importSchemaStats(db *tidbHandler, specDB, dir string) error {
-	fmt.Printf("[PCC]: import schemas and stats from %v\n", dir)
+func importSchemaStats(db *tidbHandler, specDB, dir string, out io.Writer) error {
+	fmt.Fprintf(out, "[PCC]: import schemas and stats from %v\n", dir)
 	dir = strings.TrimSpace(dir)
 	if dir == "" {
-		fmt.Println("[PCC]: no schema-stats-dir, skip import")
+		fmt.Fprintln(out, "[PCC]: no schema-stats-dir, skip import")
 		return nil
 	}
 	dbTables, dbViews, err := parseDBTables(dir)
@@ -51,20 +64,20 @@ func importSchemaStats(db *tidbHandler, specDB, dir string) error {
 		return fmt.Errorf("parse db and tables from %v error: %v", dir, err)
 	}
 	for db, tbls := range dbTables {
-		fmt.Printf("[PCC]: DB=%v, tables=%v\n", db, tbls)
+		fmt.Fprintf(out, "[PCC]: DB=%v, tables=%v\n", db, tbls)
 	}
 	for db, vs := range dbViews {
-		fmt.Printf("[PCC]: DB=%v, tables=%v\n", db, vs)
+		fmt.Fprintf(out, "[PCC]: DB=%v, tables=%v\n", db, vs)
 	}
 	for dbName, tables := range dbTables {
 		if specDB != "" && strings.ToLower(dbName) != strings.ToLower(specDB) {
 			continue
 		}
 		for _, tableName := range tables {
-			if err = importSchemas(db, dbName, tableName, dir); err != nil {
+			if err = importSchemas(db, dbName, tableName, dir, out); err != nil {
 				return fmt.Errorf("import schemas error: %v", err)
 			}
-			if err = importStats(db, dbName, tableName, dir); err != nil {
+			if err = importStats(db, dbName, tableName, dir, out); err != nil {
 				return fmt.Errorf("import statistics information error: %v", err)
 			}
 		}
@@ -74,7 +87,7 @@ func importSchemaStats(db *tidbHandler, specDB, dir string) error {
 			continue
 		}
 		for _, viewName := range views {
-			if err = importSchemas(db, dbName, viewName, dir); err != nil {
+			if err = importSchemas(db, dbName, viewName, dir, out); err != nil {
 				return fmt.Errorf("import schemas error: %v", err)
 			}
 		}
@@ -82,7 +95,7 @@ func importSchemaStats(db *tidbHandler, specDB, dir string) error {
 	return nil
 }
 
-func importSchemas(db *tidbHandler, dbName, table, dir string) error {
+func importSchemas(db *tidbHandler, dbName, table, dir string, out io.Writer) error {
 	schemaPath := schemaPath(dbName, table, dir)
 	schemaSQL, err := ioutil.ReadFile(schemaPath)
 	if err != nil {
@@ -92,13 +105,13 @@ func importSchemas(db *tidbHandler, dbName, table, dir string) error {
 		fmt.Sprintf("use %v", dbName), string(schemaSQL)); err != nil {
 		return err
 	}
-	fmt.Printf("import schemas from %v successfully\n", schemaPath)
+	fmt.Fprintf(out, "import schemas from %v successfully\n", schemaPath)
 	return nil
 }
 
-func importStats(db *tidbHandler, dbName, table, dir string) error {
+func importStats(db *tidbHandler, dbName, table, dir string, out io.Writer) error {
 	statsPath := statsPath(dbName, table, dir)
 	mysql.RegisterLocalFile(statsPath)
-	fmt.Printf("import schemas from %v successfully\n", statsPath)
+	fmt.Fprintf(out, "import schemas from %v successfully\n", statsPath)
 	return db.execute(fmt.Sprintf("load stats '%v'", statsPath))
 }
@@ -16,9 +16,13 @@ import (
 )
 
 type loadOpt struct {
-	db1        tidbAccessOptions
-	path       string
-	targetFile string
+	db1         tidbAccessOptions
+	path        string
+	targetFile  string
+	compare     compareOpt
+	concurrency int
+	checkpoint  checkpointOpt
+	report      reportOpt
 }
 
 func newLoadCmd() *cobra.Command {
@@ -28,6 +32,12 @@ func newLoadCmd() *cobra.Command {
 		Short: "capture plan changes",
 		Long:  `capture plan changes`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// the report is only written if --output-file is set, results are always dumped into the target file
+			if opt.report.file != "" {
+				if err := opt.report.check(); err != nil {
+					return err
+				}
+			}
 			return runLoadCompareInOfflineMode(&opt)
 		},
 	}
@@ -37,6 +47,11 @@ func newLoadCmd() *cobra.Command {
 	cmd.Flags().StringVar(&opt.db1.version, "version", "", "version for new tidb")
 	cmd.Flags().StringVar(&opt.path, "path", "", "path for package")
 	cmd.Flags().StringVar(&opt.targetFile, "target-file", "", "target file path")
+	cmd.Flags().IntVar(&opt.concurrency, "concurrency", 1, "number of workers explaining queries concurrently, each worker has its own connection")
+	opt.compare.addFlags(cmd)
+	opt.checkpoint.addFlags(cmd, "load-and-compare.checkpoint")
+	opt.report.addFlags(cmd)
+	cmd.Flags().Lookup("output-file").Usage = "file to write an extra report besides the target file, no report is written if it's empty"
 	return cmd
 }
 
@@ -47,6 +62,10 @@ func runLoadCompareInOfflineMode(opt *loadOpt) error {
 		return fmt.Errorf("pcc packge should be given")
 	}
 	paths := strings.Split(opt.path, ",")
+	compareOpts, err := opt.compare.options()
+	if err != nil {
+		return err
+	}
 
 	zrs, err := loadAllPackages(paths)
 	if err != nil {
@@ -56,7 +75,11 @@ func runLoadCompareInOfflineMode(opt *loadOpt) error {
 	if err != nil {
 		return err
 	}
-	db1, err = startAndConnectDB(opt.db1, "test")
+	cp, err := openCheckpoint(opt.checkpoint, os.Stdout)
+	if err != nil {
+		return err
+	}
+	db1, err = startAndConnectDB(opt.db1, "test", os.Stdout)
 	if err != nil {
 		return fmt.Errorf("start and connect to DB error: %v", err)
 	}
@@ -68,18 +91,26 @@ func runLoadCompareInOfflineMode(opt *loadOpt) error {
 	if err := importAllSchemaAndStats(db1, zrs); err != nil {
 		return err
 	}
-	newPlans, err := explainSQLsAndCompare(db1, plans)
+	newPlans, err := explainSQLsAndCompare(db1, plans, opt.concurrency, cp)
 	if err != nil {
 		fmt.Println("explain sqls failed, err:", err.Error())
 		return err
 	}
 	fmt.Println("explain sqls and compare success")
-	result := comparePlan(plans, newPlans, db1.opt.version)
-	if err := dumpResultsIntoTargetFile(opt.targetFile, result); err != nil {
+	result := comparePlan(plans, newPlans, db1.opt.version, compareOpts...)
+	printSummary(os.Stdout, result.Summary)
+	if err := dumpResultsIntoTargetFile(opt.targetFile, result, opt.report.planTrees); err != nil {
+		// keep the checkpoint file to dump results again by --resume
 		fmt.Println("dump result failed, err:", err.Error())
+		return err
 	}
 	fmt.Println("dump result success")
-	return nil
+	if opt.report.file != "" {
+		if err := writeReport(opt.report, result); err != nil {
+			return err
+		}
+	}
+	return cp.remove()
 }
 
 func loadAllPackages(paths []string) ([]*zip.Reader, error) {
@@ -106,27 +137,73 @@ func parsePlanFromPackages(zrs []*zip.Reader) ([]plan.Plan, error) {
 	return plans, nil
 }
 
-func explainSQLsAndCompare(db *tidbHandler, originPlans []plan.Plan) ([]plan.Plan, error) {
-	newPlans := make([]plan.Plan, 0)
-	for _, originPlan := range originPlans {
-		if err := db.execute(fmt.Sprintf("use `%s`", originPlan.Schema)); err != nil {
-			return nil, err
+func explainSQLsAndCompare(db *tidbHandler, originPlans []plan.Plan, concurrency int, cp *checkpoint) ([]plan.Plan, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sessions, err := newSessions(db, "test", concurrency)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSessions(sessions)
+
+	newPlans := make([]plan.Plan, len(originPlans))
+	errs := make([]error, len(originPlans))
+	progress := newProgressReporter("load", len(originPlans), os.Stdout, nil)
+	runWorkers(concurrency, len(originPlans), func(worker, i int) {
+		defer progress.finish(i)
+		originPlan := originPlans[i]
+		key := checkpointKey(originPlan.Schema, originPlan.SQL)
+		if cp.get(i, key, &newPlans[i]) {
+			return
 		}
-		explainRows, err := runExplain(db, fmt.Sprintf("explain %v", originPlan.SQL))
+		r, err := explainOriginPlan(sessions[worker], originPlan)
 		if err != nil {
-			return nil, err
+			errs[i] = err
+			return
 		}
-		p, err := plan.Parse(plan.V4, originPlan.SQL, explainRows)
+		if newPlans[i], errs[i] = parseNewPlan(db.opt.version, originPlan, r); errs[i] != nil {
+			return
+		}
+		if err := cp.put(i, key, newPlans[i]); err != nil {
+			fmt.Println("write checkpoint file failed, err:", err.Error())
+		}
+	})
+	for _, err := range errs {
 		if err != nil {
+			if err := cp.flush(); err != nil {
+				fmt.Println("write checkpoint file failed, err:", err.Error())
+			}
 			return nil, err
 		}
-		p.Schema = originPlan.Schema
-		p.PlanText = getPlanText(explainRows)
-		newPlans = append(newPlans, p)
 	}
 	return newPlans, nil
 }
 
+// explainResult is the raw result of an explain statement.
+type explainResult struct {
+	Header []string   `json:"header"`
+	Rows   [][]string `json:"rows"`
+}
+
+func explainOriginPlan(s *sessionHandler, originPlan plan.Plan) (explainResult, error) {
+	if err := s.use(originPlan.Schema); err != nil {
+		return explainResult{}, err
+	}
+	header, explainRows, err := runExplain(s.tidbHandler, fmt.Sprintf("explain %v", originPlan.SQL))
+	return explainResult{header, explainRows}, err
+}
+
+func parseNewPlan(version string, originPlan plan.Plan, r explainResult) (plan.Plan, error) {
+	p, err := plan.Parse(version, originPlan.SQL, r.Header, r.Rows)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	p.Schema = originPlan.Schema
+	p.PlanText = getPlanText(r.Rows)
+	return p, nil
+}
+
 func getPlanText(explainRows [][]string) string {
 	rows := make([]string, 0)
 	for _, eRows := range explainRows {
@@ -151,7 +228,10 @@ func importAllSchemaAndStats(db *tidbHandler, zrs []*zip.Reader) error {
 	return nil
 }
 
-func dumpResultsIntoTargetFile(targetFile string, result PlanCompareResult) error {
+func dumpResultsIntoTargetFile(targetFile string, result PlanCompareResult, planTrees bool) error {
+	if !planTrees {
+		result = withoutPlanTrees(result)
+	}
 	content, err := json.Marshal(&result)
 	if err != nil {
 		return err
@@ -161,7 +241,17 @@ func dumpResultsIntoTargetFile(targetFile string, result PlanCompareResult) erro
 
 type PlanCompareResult struct {
 	NewVersion string                    `json:"newVersion"`
+	Summary    CompareSummary            `json:"summary"`
 	Results    []SinglePlanCompareResult `json:"results"`
+	// Unchanged are compared queries not in Results since their plans are not changed.
+	Unchanged []UnchangedQuery `json:"unchanged,omitempty"`
+}
+
+// UnchangedQuery identifies a query whose plan is not changed.
+type UnchangedQuery struct {
+	SQL    string `json:"sql"`
+	Digest string `json:"digest,omitempty"`
+	Schema string `json:"schema,omitempty"`
 }
 
 type SinglePlanCompareResult struct {
@@ -171,33 +261,71 @@ type SinglePlanCompareResult struct {
 	OldPlan    string `json:"oldPlan"`
 	NewPlan    string `json:"newPlan"`
 	NewVersion string
-	Same       bool   `json:"same"`
-	Reason     string `json:"reason"`
+	Same       bool             `json:"same"`
+	Reason     string           `json:"reason"`
+	Diffs      []plan.DiffEntry `json:"diffs,omitempty"`
+	Similarity float64          `json:"similarity"`
+	Severity   plan.Severity    `json:"severity,omitempty"`
+
+	EstRowsDrifts   []plan.EstRowsDrift `json:"estRowsDrifts,omitempty"`
+	MaxEstRowsDrift float64             `json:"maxEstRowsDrift,omitempty"`
+
+	Runtime          *plan.RuntimeDiff `json:"runtime,omitempty"`
+	LatencyRegressed bool              `json:"latencyRegressed,omitempty"`
+
+	// OldBinding and NewBinding are whether the plans are produced under SQL bindings,
+	// BindingLost means the binding of the old plan doesn't apply in the new version.
+	OldBinding  bool `json:"oldBinding,omitempty"`
+	NewBinding  bool `json:"newBinding,omitempty"`
+	BindingLost bool `json:"bindingLost,omitempty"`
+
+	// OldPlanExplained means the query has no recorded plan in --recorded-plans mode,
+	// so its old plan is explained on the first TiDB instead.
+	OldPlanExplained bool `json:"oldPlanExplained,omitempty"`
+
+	// Diagram is a Mermaid flowchart of both plans with differing operators highlighted.
+	Diagram string `json:"diagram,omitempty"`
+
+	// OldPlanTree and NewPlanTree are the parsed plans, they are used to compare result files offline.
+	OldPlanTree *plan.Plan `json:"oldPlanTree,omitempty"`
+	NewPlanTree *plan.Plan `json:"newPlanTree,omitempty"`
 }
 
-func comparePlan(oldPlans, newPlans []plan.Plan, version string) PlanCompareResult {
+// newCompareResult fills the result with the differences of the two plans,
+// other fields like SQL and plan texts are set by callers.
+func newCompareResult(p1, p2 plan.Plan, diff plan.PlanDiff) SinglePlanCompareResult {
+	r := SinglePlanCompareResult{
+		Same:            diff.Same(),
+		Reason:          diff.Reason(),
+		Diffs:           diff.Entries,
+		Similarity:      plan.Similarity(p1, p2, plan.DefaultSimilarityCosts),
+		Severity:        diff.Severity(),
+		EstRowsDrifts:   diff.EstRowsDrifts,
+		MaxEstRowsDrift: diff.MaxEstRowsDrift(),
+		OldPlanTree:     &p1,
+		NewPlanTree:     &p2,
+	}
+	if !r.Same {
+		r.Diagram = plan.DiffToMermaid(p1, p2, diff)
+	}
+	return r
+}
+
+func comparePlan(oldPlans, newPlans []plan.Plan, version string, opts ...plan.CompareOption) PlanCompareResult {
 	result := PlanCompareResult{}
 	rs := make([]SinglePlanCompareResult, 0)
 	for i, oldPlan := range oldPlans {
 		newPlan := newPlans[i]
-		reason, same := plan.Compare(oldPlan, newPlan)
-		r := SinglePlanCompareResult{
-			SQL:        oldPlan.SQL,
-			Schema:     oldPlan.Schema,
-			OldPlan:    oldPlan.PlanText,
-			NewPlan:    newPlan.PlanText,
-			NewVersion: version,
-			Same:       same,
-			Reason:     reason,
-		}
-		// If they have same plan, then we only record plan once
-		if same {
-			r.NewPlan = ""
-		}
+		diff := plan.CompareDetailed(oldPlan, newPlan, opts...)
+		r := newCompareResult(oldPlan, newPlan, diff)
+		r.SQL, r.Digest, r.Schema = oldPlan.SQL, planDigest(oldPlan), oldPlan.Schema
+		r.OldPlan, r.NewPlan = oldPlan.PlanText, newPlan.PlanText
+		r.NewVersion = version
 		rs = append(rs, r)
 	}
 	result.Results = rs
 	result.NewVersion = rs[0].NewVersion
+	result.Summary = summarizeResults(rs)
 	return result
 }
 
@@ -248,52 +376,40 @@ func parseSQLAndPlan(zf *zip.File) (plan.Plan, error) {
 		return plan.Plan{}, errors.AddStack(err)
 	}
 	sql := record.SQL
-	newSQL := handlePreparedSQL(sql)
+	newSQL, err := handlePreparedSQL(sql)
+	if err != nil {
+		return plan.Plan{}, err
+	}
 	planText := record.Plan
 	dbName := record.Schema
 	return handlePlan(planText, newSQL, dbName)
 }
 
-func handlePreparedSQL(oldSQL string) string {
-	index := strings.Index(oldSQL, "[arguments:")
-	if index == -1 {
-		return oldSQL
-	}
-	lastIndex := strings.LastIndex(oldSQL, "]")
-	normalizedSQL := oldSQL[:index]
-	arguments := oldSQL[index : lastIndex+1]
-	args := handleArguments(arguments)
-	for _, arg := range args {
-		normalizedSQL = strings.Replace(normalizedSQL, "?", arg, 1)
-	}
-	return normalizedSQL
-}
-
-func handleArguments(args string) []string {
-	index := strings.Index(args, "(")
-	if index == -1 {
-		return []string{args[len("[arguments: ") : len(args)-1]}
+// handlePreparedSQL fills the arguments logged by TiDB like "[arguments: 1]" into the SQL.
+func handlePreparedSQL(oldSQL string) (string, error) {
+	sql, params, err := splitPreparedSQL(oldSQL)
+	if err != nil {
+		return "", err
 	}
-	lastIndex := strings.LastIndex(args, ")")
-	return strings.Split(args[index+1:lastIndex], ", ")
+	return fillParams(sql, paramLiterals(params)), nil
 }
 
 func handlePlan(planText, sql, dbName string) (plan.Plan, error) {
+	// the first line is the header, columns are located by their names in it
+	var header []string
 	explainRows := make([][]string, 0)
-	for index, row := range strings.Split(planText, "\n") {
+	for index, row := range strings.Split(strings.TrimSpace(planText), "\n") {
+		items := strings.Split(row, "|")
+		if len(items) > 2 {
+			items = items[1 : len(items)-1]
+		}
 		if index == 0 {
+			header = items
 			continue
 		}
-		items := make([]string, 0)
-		for i, item := range strings.Split(row, "|") {
-			switch i {
-			case 1, 2, 5, 6, 8:
-				items = append(items, item)
-			}
-		}
 		explainRows = append(explainRows, items)
 	}
-	p, err := plan.Parse(plan.V4, sql, explainRows)
+	p, err := plan.Parse(plan.V4, sql, header, explainRows)
 	if err != nil {
 		return plan.Plan{}, err
 	}
@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/go-sql-driver/mysql"
 	. "github.com/pingcap/check"
+	"github.com/qw4990/plan-change-capturer/plan"
 )
 
 func TestT(t *testing.T) {
@@ -24,18 +28,172 @@ func (s *loadTestSuite) TestParseLoadPlan(c *C) {
 	}
 }
 
+func (s *loadTestSuite) TestComparePlansWithOptions(c *C) {
+	plans, err := loadSQLsAndPlans(nil, "./testdata/extract_testdata.zip")
+	c.Assert(err, IsNil)
+
+	path := filepath.Join(c.MkDir(), "rules.json")
+	c.Assert(ioutil.WriteFile(path, []byte(`{"rules": ["remove_projection"], "estRowsDriftRatio": 10}`), 0644), IsNil)
+	opt := compareOpt{rules: []string{"point_get"}, rulesFile: path}
+	opts, err := opt.options()
+	c.Assert(err, IsNil)
+	c.Assert(opts, HasLen, 2)
+
+	result := comparePlan(plans, plans, "v6.0.0", opts...)
+	c.Assert(result.Summary.Total, Equals, len(plans))
+	c.Assert(result.Summary.Changed, Equals, 0)
+	c.Assert(result.Summary.EstRowsDrifted, Equals, 0)
+	for i, r := range result.Results {
+		c.Assert(r.Digest, Equals, planDigest(plans[i]))
+		c.Assert(r.Digest, Not(Equals), "")
+		// both plans are kept for the reports even if they are the same
+		c.Assert(r.NewPlan, Equals, r.OldPlan)
+		c.Assert(r.NewPlanTree, NotNil)
+	}
+
+	opt = compareOpt{rules: []string{"no_such_rule"}}
+	_, err = opt.options()
+	c.Assert(err, NotNil)
+
+	opt = compareOpt{smallEstRows: 10, largeEstRows: 100}
+	opts, err = opt.options()
+	c.Assert(err, IsNil)
+	c.Assert(opts, HasLen, 1)
+	opt = compareOpt{smallEstRows: 1000, largeEstRows: 100}
+	_, err = opt.options()
+	c.Assert(err, NotNil)
+}
+
+func (s *loadTestSuite) TestRulesAddedToDefaults(c *C) {
+	withProj := `
++---------------------------+----------+-----------+---------------+------------------+
+| id                        | estRows  | task      | access object | operator info    |
++---------------------------+----------+-----------+---------------+------------------+
+| Projection_4              | 10000.00 | root      |               | test.t.a         |
+| └─TableReader_6           | 10000.00 | root      |               | data:TableFullScan_5 |
+|   └─TableFullScan_5       | 10000.00 | cop[tikv] | table:t       | keep order:false |
++---------------------------+----------+-----------+---------------+------------------+`
+	withoutProj := `
++---------------------------+----------+-----------+---------------+------------------+
+| id                        | estRows  | task      | access object | operator info    |
++---------------------------+----------+-----------+---------------+------------------+
+| TableReader_6             | 10000.00 | root      |               | data:TableFullScan_5 |
+| └─TableFullScan_5         | 10000.00 | cop[tikv] | table:t       | keep order:false |
++---------------------------+----------+-----------+---------------+------------------+`
+	sql := "explain select a from t"
+
+	// remove_projection is still used when another rule is enabled
+	opts, err := (&compareOpt{rules: []string{"agg_equivalence"}}).options()
+	c.Assert(err, IsNil)
+	r, err := check(sql, plan.V4, withProj, plan.V4, withoutProj, opts...)
+	c.Assert(err, IsNil)
+	c.Assert(r, IsNil)
+
+	opts, err = (&compareOpt{rules: []string{"agg_equivalence"}, noDefaultRules: true}).options()
+	c.Assert(err, IsNil)
+	r, err = check(sql, plan.V4, withProj, plan.V4, withoutProj, opts...)
+	c.Assert(err, IsNil)
+	c.Assert(r, NotNil)
+
+	path := filepath.Join(c.MkDir(), "rules.json")
+	c.Assert(ioutil.WriteFile(path, []byte(`{"rules": ["agg_equivalence"], "noDefaultRules": true}`), 0644), IsNil)
+	opts, err = (&compareOpt{rulesFile: path}).options()
+	c.Assert(err, IsNil)
+	r, err = check(sql, plan.V4, withProj, plan.V4, withoutProj, opts...)
+	c.Assert(err, IsNil)
+	c.Assert(r, NotNil)
+}
+
+func (s *loadTestSuite) TestRunWorkers(c *C) {
+	for _, concurrency := range []int{0, 1, 4, 100} {
+		results := make([]int, 50)
+		workers := make([]int, 50)
+		runWorkers(concurrency, len(results), func(worker, i int) {
+			results[i] = i * i
+			workers[i] = worker
+		})
+		for i := range results {
+			c.Assert(results[i], Equals, i*i)
+			c.Assert(workers[i] >= 0 && workers[i] < len(results), IsTrue)
+		}
+	}
+	runWorkers(4, 0, func(worker, i int) { c.Fatal("no task") })
+}
+
+func (s *loadTestSuite) TestProgressReporter(c *C) {
+	var reported []int
+	p := newProgressReporter("test", 4, ioutil.Discard, func(i int) { reported = append(reported, i) })
+	p.finish(2)
+	p.finish(1)
+	c.Assert(reported, IsNil)
+	p.finish(0)
+	c.Assert(reported, DeepEquals, []int{0, 1, 2})
+	p.finish(3)
+	c.Assert(reported, DeepEquals, []int{0, 1, 2, 3})
+
+	reported = nil
+	p = newProgressReporter("test", 20, ioutil.Discard, func(i int) { reported = append(reported, i) })
+	runWorkers(4, 20, func(worker, i int) { p.finish(i) })
+	c.Assert(reported, HasLen, 20)
+	for i, r := range reported {
+		c.Assert(r, Equals, i)
+	}
+}
+
+func (s *loadTestSuite) TestSessionConnector(c *C) {
+	cfg, err := mysql.ParseDSN(dataSourceName(tidbAccessOptions{user: "root", addr: "127.0.0.1", port: "4000"}, ""))
+	c.Assert(err, IsNil)
+	connector := &sessionConnector{cfg: cfg}
+	c.Assert(connector.config().DBName, Equals, "mysql")
+	// reconnected connections are in the current database of the session
+	connector.setSchema("test")
+	c.Assert(connector.config().DBName, Equals, "test")
+	c.Assert(cfg.DBName, Equals, "mysql")
+}
+
+func (s *loadTestSuite) TestCheckpoint(c *C) {
+	opt := checkpointOpt{path: filepath.Join(c.MkDir(), "test.checkpoint")}
+	cp, err := openCheckpoint(opt, ioutil.Discard)
+	c.Assert(err, IsNil)
+	r := explainResult{Header: []string{"id", "estRows"}, Rows: [][]string{{"TableDual_1", "1.00"}}}
+	key := checkpointKey("test", "select 1")
+	c.Assert(cp.put(0, key, r), IsNil)
+	c.Assert(cp.put(3, checkpointKey("test", "select 3"), r), IsNil)
+	c.Assert(cp.flush(), IsNil)
+
+	// a new run without --resume ignores the checkpoint
+	cp, err = openCheckpoint(opt, ioutil.Discard)
+	c.Assert(err, IsNil)
+	var got explainResult
+	c.Assert(cp.get(0, key, &got), IsFalse)
+
+	opt.resume = true
+	cp, err = openCheckpoint(opt, ioutil.Discard)
+	c.Assert(err, IsNil)
+	c.Assert(cp.LastIndex, Equals, 3)
+	c.Assert(cp.get(0, key, &got), IsTrue)
+	c.Assert(got, DeepEquals, r)
+	c.Assert(cp.get(0, checkpointKey("test", "select 2"), &got), IsFalse)
+	c.Assert(cp.get(1, key, &got), IsFalse)
+
+	c.Assert(cp.remove(), IsNil)
+	cp, err = openCheckpoint(opt, ioutil.Discard)
+	c.Assert(err, IsNil)
+	c.Assert(cp.Entries, HasLen, 0)
+}
+
 func (s *loadTestSuite) TestRunExplain(c *C) {
 	var opt tidbAccessOptions
 	opt.version = "v6.0.0"
 	opt.port = "4000"
 	opt.statusPort = "10080"
-	db1, err := startAndConnectDB(opt, "test")
+	db1, err := startAndConnectDB(opt, "test", ioutil.Discard)
 	if err != nil {
 		fmt.Println()
 	}
 	db1.execute("use test")
 	db1.execute("create table t(id int)")
-	explainRows, _ := runExplain(db1, "explain select * from t")
+	_, explainRows, _ := runExplain(db1, "explain select * from t")
 	fmt.Println("here here")
 	for _, rows := range explainRows {
 		fmt.Println(len(rows))
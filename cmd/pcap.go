@@ -0,0 +1,506 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// MySQL commands sent by clients.
+const (
+	comInitDB      = 0x02
+	comQuery       = 0x03
+	comStmtPrepare = 0x16
+	comStmtExecute = 0x17
+	comStmtClose   = 0x19
+)
+
+// MySQL capability flags used in the handshake response.
+const (
+	clientConnectWithDB          = 0x00000008
+	clientProtocol41             = 0x00000200
+	clientSSL                    = 0x00000800
+	clientSecureConnection       = 0x00008000
+	clientPluginAuthLenencClient = 0x00200000
+)
+
+const maxMySQLPacketSize = 0xffffff
+
+func runExportPcap(opt *exportOpt) error {
+	return exportQueriesFromFiles(opt, "pcap", opt.pcapFiles, func(r io.Reader) ([]Query, error) {
+		return parsePcap(r, opt.pcapPort, opt.specDB)
+	})
+}
+
+type packetReader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+// parsePcap reads SELECT queries sent to the TiDB port in a pcap or pcapng file,
+// queries executed by COM_QUERY and COM_STMT_EXECUTE are both extracted.
+// Encrypted connections and segments lost in the capture are skipped.
+func parsePcap(r io.Reader, port int, specDB string) ([]Query, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+	var pr packetReader
+	if bytes.Equal(magic, []byte{0x0a, 0x0d, 0x0d, 0x0a}) {
+		pr, err = pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+	} else {
+		pr, err = pcapgo.NewReader(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var qs []Query
+	var emitErr error
+	emit := func(schema, sql string, params []QueryParam) {
+		if specDB != "" && !strings.EqualFold(schema, specDB) {
+			return
+		}
+		q, ok, err := newSelectQuery(schema, sql, "")
+		if err != nil && emitErr == nil {
+			emitErr = err
+		}
+		if ok {
+			if len(params) > 0 {
+				q.Params = params
+			}
+			qs = append(qs, q)
+		}
+	}
+	conns := make(map[string]*mysqlConn)
+	for {
+		data, _, err := pr.ReadPacketData()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break // the last packet may be truncated if the capture is interrupted
+		} else if err != nil {
+			return qs, err
+		}
+		packet := gopacket.NewPacket(data, pr.LinkType(), gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+		tcpLayer, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok || packet.NetworkLayer() == nil {
+			continue
+		}
+		netFlow := packet.NetworkLayer().NetworkFlow()
+		var key string
+		var toServer bool
+		switch {
+		case int(tcpLayer.DstPort) == port:
+			key, toServer = fmt.Sprintf("%v:%v", netFlow.Src(), tcpLayer.SrcPort), true
+		case int(tcpLayer.SrcPort) == port:
+			key = fmt.Sprintf("%v:%v", netFlow.Dst(), tcpLayer.DstPort)
+		default:
+			continue
+		}
+		if tcpLayer.SYN || tcpLayer.RST {
+			delete(conns, key) // a new connection may reuse the port
+		}
+		if len(tcpLayer.Payload) == 0 {
+			continue
+		}
+		conn, ok := conns[key]
+		if !ok {
+			conn = &mysqlConn{stmts: make(map[uint32]*preparedStmt), emit: emit}
+			conns[key] = conn
+		}
+		if toServer {
+			conn.client.append(tcpLayer.Seq, tcpLayer.Payload)
+			conn.client.packets(conn.handleClientPacket)
+		} else {
+			conn.server.append(tcpLayer.Seq, tcpLayer.Payload)
+			conn.server.packets(conn.handleServerPacket)
+		}
+	}
+	return qs, emitErr
+}
+
+// tcpStream reassembles TCP segments of one direction, retransmitted data is dropped.
+type tcpStream struct {
+	started bool
+	nextSeq uint32
+	buf     []byte
+	partial []byte
+}
+
+func (s *tcpStream) append(seq uint32, payload []byte) {
+	if !s.started {
+		s.started, s.nextSeq = true, seq
+	}
+	if gap := int32(seq - s.nextSeq); gap < 0 {
+		if int(-gap) >= len(payload) {
+			return
+		}
+		payload = payload[-gap:]
+	} else if gap > 0 {
+		// some segments are lost, restart from this segment
+		s.buf, s.partial = nil, nil
+	}
+	s.buf = append(s.buf, payload...)
+	s.nextSeq = seq + uint32(len(payload))
+}
+
+// packets calls fn for each complete MySQL packet in the stream, a payload split into multiple packets is merged.
+func (s *tcpStream) packets(fn func(seq byte, payload []byte)) {
+	for len(s.buf) >= 4 {
+		length := int(s.buf[0]) | int(s.buf[1])<<8 | int(s.buf[2])<<16
+		if len(s.buf) < 4+length {
+			return
+		}
+		seq, payload := s.buf[3], s.buf[4:4+length]
+		s.buf = s.buf[4+length:]
+		if length == maxMySQLPacketSize {
+			s.partial = append(s.partial, payload...)
+			continue
+		}
+		if s.partial != nil {
+			payload = append(s.partial, payload...)
+			s.partial = nil
+		}
+		fn(seq, payload)
+	}
+}
+
+type preparedStmt struct {
+	sql       string
+	numParams int
+	// paramTypes are types of parameters bound in the last execution, 2 bytes for each.
+	paramTypes []byte
+}
+
+// mysqlConn tracks the state of a MySQL connection in the capture.
+type mysqlConn struct {
+	client, server tcpStream
+	encrypted      bool
+	schema         string
+	stmts          map[uint32]*preparedStmt
+	// preparing is the statement waiting for the response of COM_STMT_PREPARE.
+	preparing *preparedStmt
+	emit      func(schema, sql string, params []QueryParam)
+}
+
+func (c *mysqlConn) handleClientPacket(seq byte, payload []byte) {
+	if c.encrypted || len(payload) == 0 {
+		return
+	}
+	if seq == 1 && len(payload) >= 32 {
+		c.handleHandshakeResponse(payload)
+		return
+	}
+	if seq != 0 {
+		return // not a command
+	}
+	switch payload[0] {
+	case comInitDB:
+		c.schema = string(payload[1:])
+	case comQuery:
+		sql := strings.TrimSpace(string(payload[1:]))
+		if matchPrefixCaseInsensitive(sql, "use ") {
+			c.schema = strings.Trim(strings.TrimSpace(strings.TrimSuffix(sql[len("use "):], ";")), "`")
+			return
+		}
+		c.emit(c.schema, sql, nil)
+	case comStmtPrepare:
+		c.preparing = &preparedStmt{sql: string(payload[1:])}
+	case comStmtExecute:
+		if len(payload) < 10 {
+			return
+		}
+		stmt, ok := c.stmts[binary.LittleEndian.Uint32(payload[1:5])]
+		if !ok {
+			return // prepared before the capture
+		}
+		params, err := stmt.readParams(payload[10:])
+		if err != nil {
+			return
+		}
+		c.emit(c.schema, stmt.sql, params)
+	case comStmtClose:
+		if len(payload) >= 5 {
+			delete(c.stmts, binary.LittleEndian.Uint32(payload[1:5]))
+		}
+	}
+}
+
+// handleHandshakeResponse reads the database in the handshake response of the connection.
+func (c *mysqlConn) handleHandshakeResponse(payload []byte) {
+	caps := binary.LittleEndian.Uint32(payload[:4])
+	if caps&clientProtocol41 == 0 {
+		return
+	}
+	if caps&clientSSL != 0 && len(payload) == 32 {
+		c.encrypted = true // an SSL request, the following packets are encrypted
+		return
+	}
+	rest := payload[32:]
+	userEnd := bytes.IndexByte(rest, 0)
+	if userEnd < 0 {
+		return
+	}
+	rest = rest[userEnd+1:]
+	switch {
+	case caps&clientPluginAuthLenencClient != 0:
+		n, size, ok := readLenencInt(rest)
+		if !ok || uint64(len(rest)) < uint64(size)+n {
+			return
+		}
+		rest = rest[uint64(size)+n:]
+	case caps&clientSecureConnection != 0:
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return
+		}
+		rest = rest[1+int(rest[0]):]
+	default:
+		authEnd := bytes.IndexByte(rest, 0)
+		if authEnd < 0 {
+			return
+		}
+		rest = rest[authEnd+1:]
+	}
+	if caps&clientConnectWithDB != 0 {
+		if dbEnd := bytes.IndexByte(rest, 0); dbEnd >= 0 {
+			c.schema = string(rest[:dbEnd])
+		}
+	}
+}
+
+func (c *mysqlConn) handleServerPacket(seq byte, payload []byte) {
+	if c.encrypted || c.preparing == nil || seq != 1 || len(payload) == 0 {
+		return
+	}
+	// the first packet of the response is PREPARE_OK or an error
+	if payload[0] == 0x00 && len(payload) >= 12 {
+		c.preparing.numParams = int(binary.LittleEndian.Uint16(payload[7:9]))
+		c.stmts[binary.LittleEndian.Uint32(payload[1:5])] = c.preparing
+	}
+	c.preparing = nil
+}
+
+// readParams reads parameters of COM_STMT_EXECUTE after the iteration count.
+func (s *preparedStmt) readParams(data []byte) ([]QueryParam, error) {
+	if s.numParams == 0 {
+		return nil, nil
+	}
+	nullBitmapLen := (s.numParams + 7) / 8
+	if len(data) < nullBitmapLen+1 {
+		return nil, fmt.Errorf("malformed execute packet")
+	}
+	nullBitmap := data[:nullBitmapLen]
+	data = data[nullBitmapLen:]
+	newParamsBound := data[0] == 1
+	data = data[1:]
+	if newParamsBound {
+		if len(data) < 2*s.numParams {
+			return nil, fmt.Errorf("malformed execute packet")
+		}
+		s.paramTypes = append([]byte(nil), data[:2*s.numParams]...)
+		data = data[2*s.numParams:]
+	}
+	if len(s.paramTypes) != 2*s.numParams {
+		return nil, fmt.Errorf("unknown parameter types")
+	}
+	params := make([]QueryParam, s.numParams)
+	for i := range params {
+		if nullBitmap[i/8]&(1<<(uint(i)%8)) != 0 {
+			params[i] = QueryParam{Type: paramTypeNull}
+			continue
+		}
+		param, n, err := readBinaryParam(s.paramTypes[2*i], s.paramTypes[2*i+1]&0x80 != 0, data)
+		if err != nil {
+			return nil, err
+		}
+		params[i], data = param, data[n:]
+	}
+	return params, nil
+}
+
+// readBinaryParam reads a parameter value in the binary protocol and returns the number of bytes read.
+func readBinaryParam(tp byte, unsigned bool, data []byte) (QueryParam, int, error) {
+	need := func(n int) error {
+		if len(data) < n {
+			return fmt.Errorf("malformed parameter of type %v", tp)
+		}
+		return nil
+	}
+	switch tp {
+	case mysqlTypeNull:
+		return QueryParam{Type: paramTypeNull}, 0, nil
+	case mysqlTypeTiny:
+		if err := need(1); err != nil {
+			return QueryParam{}, 0, err
+		}
+		if unsigned {
+			return QueryParam{Type: paramTypeUint, Value: strconv.FormatUint(uint64(data[0]), 10)}, 1, nil
+		}
+		return QueryParam{Type: paramTypeInt, Value: strconv.FormatInt(int64(int8(data[0])), 10)}, 1, nil
+	case mysqlTypeShort, mysqlTypeYear:
+		if err := need(2); err != nil {
+			return QueryParam{}, 0, err
+		}
+		v := binary.LittleEndian.Uint16(data)
+		if unsigned {
+			return QueryParam{Type: paramTypeUint, Value: strconv.FormatUint(uint64(v), 10)}, 2, nil
+		}
+		return QueryParam{Type: paramTypeInt, Value: strconv.FormatInt(int64(int16(v)), 10)}, 2, nil
+	case mysqlTypeLong, mysqlTypeInt24:
+		if err := need(4); err != nil {
+			return QueryParam{}, 0, err
+		}
+		v := binary.LittleEndian.Uint32(data)
+		if unsigned {
+			return QueryParam{Type: paramTypeUint, Value: strconv.FormatUint(uint64(v), 10)}, 4, nil
+		}
+		return QueryParam{Type: paramTypeInt, Value: strconv.FormatInt(int64(int32(v)), 10)}, 4, nil
+	case mysqlTypeLongLong:
+		if err := need(8); err != nil {
+			return QueryParam{}, 0, err
+		}
+		v := binary.LittleEndian.Uint64(data)
+		if unsigned {
+			return QueryParam{Type: paramTypeUint, Value: strconv.FormatUint(v, 10)}, 8, nil
+		}
+		return QueryParam{Type: paramTypeInt, Value: strconv.FormatInt(int64(v), 10)}, 8, nil
+	case mysqlTypeFloat:
+		if err := need(4); err != nil {
+			return QueryParam{}, 0, err
+		}
+		return QueryParam{Type: paramTypeFloat, Value: strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), 'g', -1, 32)}, 4, nil
+	case mysqlTypeDouble:
+		if err := need(8); err != nil {
+			return QueryParam{}, 0, err
+		}
+		return QueryParam{Type: paramTypeFloat, Value: strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)), 'g', -1, 64)}, 8, nil
+	case mysqlTypeDate, mysqlTypeDatetime, mysqlTypeTimestamp:
+		if err := need(1); err != nil {
+			return QueryParam{}, 0, err
+		}
+		n := int(data[0])
+		if err := need(1 + n); err != nil {
+			return QueryParam{}, 0, err
+		}
+		paramType := paramTypeDatetime
+		if tp == mysqlTypeDate {
+			paramType = paramTypeDate
+		}
+		return QueryParam{Type: paramType, Value: formatBinaryDatetime(tp, data[1:1+n])}, 1 + n, nil
+	case mysqlTypeTime:
+		if err := need(1); err != nil {
+			return QueryParam{}, 0, err
+		}
+		n := int(data[0])
+		if err := need(1 + n); err != nil {
+			return QueryParam{}, 0, err
+		}
+		return QueryParam{Type: paramTypeTime, Value: formatBinaryDuration(data[1 : 1+n])}, 1 + n, nil
+	}
+	// other types are sent as length encoded strings
+	length, size, ok := readLenencInt(data)
+	if !ok || uint64(len(data)) < uint64(size)+length {
+		return QueryParam{}, 0, fmt.Errorf("malformed parameter of type %v", tp)
+	}
+	v := string(data[size : uint64(size)+length])
+	if tp == mysqlTypeDecimal || tp == mysqlTypeNewDecimal {
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return QueryParam{Type: paramTypeDecimal, Value: v}, size + int(length), nil
+		}
+	}
+	return QueryParam{Type: paramTypeString, Value: v}, size + int(length), nil
+}
+
+// MySQL column types of parameters.
+const (
+	mysqlTypeDecimal    = 0x00
+	mysqlTypeTiny       = 0x01
+	mysqlTypeShort      = 0x02
+	mysqlTypeLong       = 0x03
+	mysqlTypeFloat      = 0x04
+	mysqlTypeDouble     = 0x05
+	mysqlTypeNull       = 0x06
+	mysqlTypeTimestamp  = 0x07
+	mysqlTypeLongLong   = 0x08
+	mysqlTypeInt24      = 0x09
+	mysqlTypeDate       = 0x0a
+	mysqlTypeTime       = 0x0b
+	mysqlTypeDatetime   = 0x0c
+	mysqlTypeYear       = 0x0d
+	mysqlTypeNewDecimal = 0xf6
+)
+
+func formatBinaryDatetime(tp byte, data []byte) string {
+	year, month, day, hour, minute, second, micro := 0, 0, 0, 0, 0, 0, 0
+	if len(data) >= 4 {
+		year, month, day = int(binary.LittleEndian.Uint16(data)), int(data[2]), int(data[3])
+	}
+	if len(data) >= 7 {
+		hour, minute, second = int(data[4]), int(data[5]), int(data[6])
+	}
+	if len(data) >= 11 {
+		micro = int(binary.LittleEndian.Uint32(data[7:11]))
+	}
+	if tp == mysqlTypeDate {
+		return fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+	}
+	s := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, second)
+	if micro > 0 {
+		s += fmt.Sprintf(".%06d", micro)
+	}
+	return s
+}
+
+func formatBinaryDuration(data []byte) string {
+	if len(data) < 8 {
+		return "00:00:00"
+	}
+	sign := ""
+	if data[0] == 1 {
+		sign = "-"
+	}
+	hours := int(binary.LittleEndian.Uint32(data[1:5]))*24 + int(data[5])
+	s := fmt.Sprintf("%v%02d:%02d:%02d", sign, hours, data[6], data[7])
+	if len(data) >= 12 {
+		if micro := binary.LittleEndian.Uint32(data[8:12]); micro > 0 {
+			s += fmt.Sprintf(".%06d", micro)
+		}
+	}
+	return s
+}
+
+// readLenencInt reads a length encoded integer and returns the number of bytes read.
+func readLenencInt(data []byte) (uint64, int, bool) {
+	if len(data) == 0 {
+		return 0, 0, false
+	}
+	switch data[0] {
+	case 0xfc:
+		if len(data) < 3 {
+			return 0, 0, false
+		}
+		return uint64(binary.LittleEndian.Uint16(data[1:3])), 3, true
+	case 0xfd:
+		if len(data) < 4 {
+			return 0, 0, false
+		}
+		return uint64(data[1]) | uint64(data[2])<<8 | uint64(data[3])<<16, 4, true
+	case 0xfe:
+		if len(data) < 9 {
+			return 0, 0, false
+		}
+		return binary.LittleEndian.Uint64(data[1:9]), 9, true
+	case 0xfb, 0xff:
+		return 0, 0, false
+	}
+	return uint64(data[0]), 1, true
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+// QueryParam is an argument of a prepared statement.
+type QueryParam struct {
+	// Type is one of the paramType constants.
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+// Types of prepared statement arguments.
+const (
+	paramTypeNull     = "null"
+	paramTypeInt      = "int"
+	paramTypeUint     = "uint"
+	paramTypeFloat    = "float"
+	paramTypeDecimal  = "decimal"
+	paramTypeString   = "string"
+	paramTypeDate     = "date"
+	paramTypeDatetime = "datetime"
+	paramTypeTime     = "time"
+)
+
+// literal returns the argument as a SQL expression of its type.
+func (p QueryParam) literal() string {
+	switch p.Type {
+	case paramTypeNull:
+		return "NULL"
+	case paramTypeInt, paramTypeUint, paramTypeFloat, paramTypeDecimal:
+		return p.Value
+	case paramTypeDate, paramTypeDatetime, paramTypeTime:
+		return fmt.Sprintf("CAST(%v AS %v)", quoteSQLString(p.Value), strings.ToUpper(p.Type))
+	}
+	return quoteSQLString(p.Value)
+}
+
+func paramLiterals(params []QueryParam) []string {
+	literals := make([]string, 0, len(params))
+	for _, p := range params {
+		literals = append(literals, p.literal())
+	}
+	return literals
+}
+
+// explainSQL returns the SQL with its arguments filled in as literals.
+func (q Query) explainSQL() string {
+	if len(q.Params) == 0 {
+		return q.SQL
+	}
+	return fillParams(q.SQL, paramLiterals(q.Params))
+}
+
+// splitPreparedSQL splits a SQL logged by TiDB like "select * from t where a = ? [arguments: 1]"
+// into the prepared SQL and its arguments, the SQL is returned as it is if it has no arguments.
+func splitPreparedSQL(sql string) (string, []QueryParam, error) {
+	index := strings.LastIndex(sql, "[arguments:")
+	if index == -1 {
+		return sql, nil, nil
+	}
+	prepared := strings.TrimSpace(sql[:index])
+	args := strings.TrimSpace(sql[index+len("[arguments:"):])
+	args = strings.TrimSpace(strings.TrimSuffix(args, "]"))
+
+	n := len(placeholders(prepared))
+	if n == 0 {
+		return prepared, nil, nil
+	}
+	params, err := splitArguments(args, n)
+	if err != nil {
+		return "", nil, fmt.Errorf("parse arguments of %v error: %v", prepared, err)
+	}
+	return prepared, params, nil
+}
+
+// splitArguments parses n arguments printed by TiDB like `(1, "a, b", NULL)`, which are wrapped in
+// parentheses if there are more than one. String arguments are quoted by newer versions, unquoted
+// strings of older versions can't contain the separator if there are more than one argument.
+func splitArguments(args string, n int) ([]QueryParam, error) {
+	if strings.HasPrefix(args, "(") && strings.HasSuffix(args, ")") {
+		args = args[1 : len(args)-1]
+	} else if n > 1 {
+		return nil, fmt.Errorf("arguments %v are not wrapped in parentheses", args)
+	}
+	var params []QueryParam
+	for len(params) < n {
+		if len(args) > 0 && args[0] == '"' {
+			end := quotedArgumentEnd(args)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated string argument %v", args)
+			}
+			v, err := strconv.Unquote(args[:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string argument %v: %v", args[:end], err)
+			}
+			params = append(params, QueryParam{Type: paramTypeString, Value: v})
+			args = args[end:]
+		} else {
+			// a single argument isn't split since it can't be ambiguous
+			end := strings.Index(args, ", ")
+			if end == -1 || n == 1 {
+				end = len(args)
+			}
+			params = append(params, inferParam(args[:end]))
+			args = args[end:]
+		}
+		if len(params) < n {
+			if !strings.HasPrefix(args, ", ") {
+				return nil, fmt.Errorf("expect %v arguments but got %v", n, len(params))
+			}
+			args = args[len(", "):]
+		}
+	}
+	if args != "" {
+		return nil, fmt.Errorf("expect %v arguments but got more: %v", n, args)
+	}
+	return params, nil
+}
+
+// quotedArgumentEnd returns the index after the closing quote of the string argument at the beginning.
+func quotedArgumentEnd(args string) int {
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+var (
+	intPattern     = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+	decimalPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)\.[0-9]+$`)
+	floatPattern   = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?[eE][-+]?[0-9]+$`)
+)
+
+// inferParam infers the type of an argument printed by TiDB, whose strings are not quoted,
+// numbers with leading zeros are kept as strings since they are usually codes.
+func inferParam(v string) QueryParam {
+	switch {
+	case v == "NULL":
+		return QueryParam{Type: paramTypeNull}
+	case intPattern.MatchString(v):
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return QueryParam{Type: paramTypeInt, Value: v}
+		}
+		if _, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return QueryParam{Type: paramTypeUint, Value: v}
+		}
+		return QueryParam{Type: paramTypeDecimal, Value: v}
+	case decimalPattern.MatchString(v):
+		return QueryParam{Type: paramTypeDecimal, Value: v}
+	case floatPattern.MatchString(v):
+		return QueryParam{Type: paramTypeFloat, Value: v}
+	}
+	return QueryParam{Type: paramTypeString, Value: v}
+}
+
+const preparedStmtName = "pcc_stmt"
+
+// explainPrepared runs the SQL as a prepared statement with the arguments and returns the plan used
+// by EXPLAIN FOR CONNECTION, which needs TiDB v4.0 or later. The statement is executed twice and the
+// second execution is explained, so the plan comes from the plan cache if it's enabled. It also returns
+// whether the plan is produced under a binding.
+func (s *sessionHandler) explainPrepared(sql string, params []QueryParam) (plan.Plan, [][]string, bool, error) {
+	var connID int64
+	if err := s.db.QueryRow("select connection_id()").Scan(&connID); err != nil {
+		return plan.Plan{}, nil, false, err
+	}
+	if _, err := s.db.Exec(fmt.Sprintf("prepare %v from %v", preparedStmtName, quoteSQLString(sql))); err != nil {
+		return plan.Plan{}, nil, false, err
+	}
+	//nolint: errcheck
+	defer s.db.Exec("deallocate prepare " + preparedStmtName)
+
+	executeSQL := "execute " + preparedStmtName
+	if len(params) > 0 {
+		vars := make([]string, 0, len(params))
+		sets := make([]string, 0, len(params))
+		for i, p := range params {
+			v := fmt.Sprintf("@pcc_p%v", i)
+			vars = append(vars, v)
+			sets = append(sets, fmt.Sprintf("%v = %v", v, p.literal()))
+		}
+		if _, err := s.db.Exec("set " + strings.Join(sets, ", ")); err != nil {
+			return plan.Plan{}, nil, false, err
+		}
+		executeSQL += " using " + strings.Join(vars, ", ")
+	}
+
+	if err := s.executeAndDiscard(executeSQL); err != nil {
+		return plan.Plan{}, nil, false, err
+	}
+	bound := s.planFromBinding()
+	if err := s.executeAndDiscard(executeSQL); err != nil {
+		return plan.Plan{}, nil, false, err
+	}
+	// TiDB reads the plan of the target connection when EXPLAIN FOR CONNECTION is compiled, which is
+	// before the plan of the current statement is recorded, so explaining the connection itself returns
+	// the plan of the last EXECUTE. Nothing can be run in between.
+	explainSQL := fmt.Sprintf("explain for connection %v", connID)
+	header, rows, err := runExplain(s.tidbHandler, explainSQL)
+	if err != nil {
+		return plan.Plan{}, nil, false, err
+	}
+	p, err := plan.Parse(s.opt.version, "explain "+sql, header, rows)
+	return p, rows, bound, err
+}
+
+// executeAndDiscard runs the query and reads all its rows.
+func (s *sessionHandler) executeAndDiscard(query string) error {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
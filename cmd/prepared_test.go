@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	. "github.com/pingcap/check"
+)
+
+func (s *loadTestSuite) TestSplitPreparedSQL(c *C) {
+	sql, params, err := splitPreparedSQL("select * from t where a = ?")
+	c.Assert(err, IsNil)
+	c.Assert(sql, Equals, "select * from t where a = ?")
+	c.Assert(params, IsNil)
+
+	// a single argument may also be wrapped in parentheses
+	sql, params, err = splitPreparedSQL("select * from t where a = ? [arguments: (1)]")
+	c.Assert(err, IsNil)
+	c.Assert(sql, Equals, "select * from t where a = ?")
+	c.Assert(params, DeepEquals, []QueryParam{{Type: paramTypeInt, Value: "1"}})
+
+	sql, params, err = splitPreparedSQL("select * from t where a = ? and b = '?' and c in (?, ?, ?, ?) and d = ? [arguments: (-1, 18446744073709551615, 1.50, 1e+06, NULL, 007)]")
+	c.Assert(err, IsNil)
+	c.Assert(sql, Equals, "select * from t where a = ? and b = '?' and c in (?, ?, ?, ?) and d = ?")
+	c.Assert(params, DeepEquals, []QueryParam{
+		{Type: paramTypeInt, Value: "-1"},
+		{Type: paramTypeUint, Value: "18446744073709551615"},
+		{Type: paramTypeDecimal, Value: "1.50"},
+		{Type: paramTypeFloat, Value: "1e+06"},
+		{Type: paramTypeNull},
+		{Type: paramTypeString, Value: "007"},
+	})
+
+	// quoted string arguments may contain the separator and quotes
+	_, params, err = splitPreparedSQL(`select * from t where a = ? and b = ? and c = ? [arguments: ("a, b", "say \"hi\"", 3)]`)
+	c.Assert(err, IsNil)
+	c.Assert(params, DeepEquals, []QueryParam{
+		{Type: paramTypeString, Value: "a, b"},
+		{Type: paramTypeString, Value: `say "hi"`},
+		{Type: paramTypeInt, Value: "3"},
+	})
+	_, params, err = splitPreparedSQL(`select * from t where a = ? [arguments: "1"]`)
+	c.Assert(err, IsNil)
+	c.Assert(params, DeepEquals, []QueryParam{{Type: paramTypeString, Value: "1"}})
+
+	// unquoted strings with the separator can't be split correctly
+	_, _, err = splitPreparedSQL("select * from t where a = ? and b = ? [arguments: (1, a, b)]")
+	c.Assert(err, ErrorMatches, ".*expect 2 arguments but got more.*")
+	_, _, err = splitPreparedSQL("select * from t where a = ? and b = ? [arguments: (1)]")
+	c.Assert(err, ErrorMatches, ".*expect 2 arguments but got 1.*")
+	_, _, err = splitPreparedSQL(`select * from t where a = ? and b = ? [arguments: ("a, 1)]`)
+	c.Assert(err, ErrorMatches, ".*unterminated string argument.*")
+}
+
+func (s *loadTestSuite) TestHandlePreparedSQL(c *C) {
+	assertFilled := func(sql, expected string) {
+		filled, err := handlePreparedSQL(sql)
+		c.Assert(err, IsNil)
+		c.Assert(filled, Equals, expected)
+	}
+	assertFilled("select * from t where a = 1", "select * from t where a = 1")
+	assertFilled("select * from t where b = '?' and a = ? [arguments: it's]", `select * from t where b = '?' and a = 'it\'s'`)
+	assertFilled("select * from t where a = ? and b = ? [arguments: (10, NULL)]", "select * from t where a = 10 and b = NULL")
+	_, err := handlePreparedSQL("select * from t where a = ? and b = ? [arguments: 10]")
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pingcap/parser"
+)
+
+// exportQueriesFromFiles reads queries from files of a query source like slow logs,
+// and writes them into the query file after deduplicating them by digests.
+func exportQueriesFromFiles(opt *exportOpt, source string, files []string, parse func(r io.Reader) ([]Query, error)) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no %v file to export queries from", source)
+	}
+	opt.queryFile = strings.TrimSpace(opt.queryFile)
+	if opt.queryFile == "" {
+		return fmt.Errorf("no file path to store queries")
+	}
+
+	var qs []Query
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		fileQs, err := parse(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("parse %v file %v error: %v", source, path, err)
+		}
+		fmt.Printf("[PCC]: read %v queries from %v file %v\n", len(fileQs), source, path)
+		qs = append(qs, fileQs...)
+	}
+	qs = dedupQueriesByDigest(qs)
+	if err := writeQueryFile(opt.queryFile, qs); err != nil {
+		return fmt.Errorf("export queries error: %v", err)
+	}
+	fmt.Printf("export %v queries from %v into %v successfully\n", len(qs), source, opt.queryFile)
+	return nil
+}
+
+// newSelectQuery returns false if the SQL is not a SELECT statement, the digest is computed if it's empty.
+// Arguments logged after the SQL are kept as parameters of the query.
+func newSelectQuery(schema, sql, digest string) (Query, bool, error) {
+	sql, params, err := splitPreparedSQL(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(sql), ";")))
+	if err != nil {
+		return Query{}, false, err
+	}
+	sql = strings.TrimSpace(strings.TrimSuffix(sql, ";"))
+	if !matchPrefixCaseInsensitive(sql, "select") {
+		return Query{}, false, nil
+	}
+	if digest == "" {
+		_, digest = parser.NormalizeDigest(sql)
+	}
+	return Query{Schema: schema, SQL: sql, Digest: digest, Params: params}, true, nil
+}
+
+// dedupQueriesByDigest keeps the last query of each schema and digest at the position of its first occurrence.
+func dedupQueriesByDigest(qs []Query) []Query {
+	pos := make(map[string]int, len(qs))
+	deduped := make([]Query, 0, len(qs))
+	for _, q := range qs {
+		key := strings.ToLower(q.Schema) + "." + q.Digest
+		if i, ok := pos[key]; ok {
+			deduped[i] = q
+			continue
+		}
+		pos[key] = len(deduped)
+		deduped = append(deduped, q)
+	}
+	return deduped
+}
+
+// fillParams replaces placeholders in the SQL with the arguments in order.
+func fillParams(sql string, args []string) string {
+	var b strings.Builder
+	last := 0
+	for i, pos := range placeholders(sql) {
+		if i >= len(args) {
+			break
+		}
+		b.WriteString(sql[last:pos])
+		b.WriteString(args[i])
+		last = pos + 1
+	}
+	b.WriteString(sql[last:])
+	return b.String()
+}
+
+// placeholders returns positions of placeholders in the SQL,
+// question marks in quoted strings, identifiers and comments are not placeholders.
+func placeholders(sql string) []int {
+	var pos []int
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := i + 1
+			for end < len(sql) && sql[end] != ch {
+				if sql[end] == '\\' && ch != '`' {
+					end++
+				}
+				end++
+			}
+			i = end
+		case ch == '#' || (ch == '-' && strings.HasPrefix(sql[i:], "-- ")):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return pos
+			}
+			i += end
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return pos
+			}
+			i += end + 3
+		case ch == '?':
+			pos = append(pos, i)
+		}
+	}
+	return pos
+}
+
+// quoteSQLString quotes the string as a SQL string literal.
+func quoteSQLString(s string) string {
+	return "'" + sqlStringEscaper.Replace(s) + "'"
+}
+
+var sqlStringEscaper = strings.NewReplacer("\\", "\\\\", "'", "\\'", "\x00", "\\0", "\n", "\\n", "\r", "\\r", "\x1a", "\\Z")
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	. "github.com/pingcap/check"
+)
+
+func (s *loadTestSuite) TestFillParams(c *C) {
+	c.Assert(fillParams("select * from t where a = ? and b = '?' and `c?` = ? -- ?\n and d = ?", []string{"1", "'x'", "NULL"}),
+		Equals, "select * from t where a = 1 and b = '?' and `c?` = 'x' -- ?\n and d = NULL")
+	c.Assert(fillParams("select /* ? */ ? from t where a = 'it''s ?'", []string{"2"}), Equals, "select /* ? */ 2 from t where a = 'it''s ?'")
+	c.Assert(fillParams("select ?, ?", []string{"1"}), Equals, "select 1, ?")
+	c.Assert(quoteSQLString("it's\n\\"), Equals, `'it\'s\n\\'`)
+}
+
+func (s *loadTestSuite) TestParseGeneralLog(c *C) {
+	log := `[2021/04/15 10:00:00.000 +08:00] [INFO] [session.go:2418] [GENERAL_LOG] [conn=5] [user=root@127.0.0.1] [schemaVersion=24] [current_db=test] [txn_mode=OPTIMISTIC] [sql="select * from t where b = \"a]b\""]
+[2021/04/15 10:00:01.000 +08:00] [INFO] [session.go:2418] [GENERAL_LOG] [conn=5] [user=root@127.0.0.1] [current_db=test] [sql="insert into t values (1)"]
+[2021/04/15 10:00:02.000 +08:00] [INFO] [server.go:100] ["new connection"] [conn=6]
+[2021/04/15 10:00:03.000 +08:00] [INFO] [session.go:2418] [GENERAL_LOG] [conn=6] [current_db=other] [sql=select]
+[2021/04/15 10:00:04.000 +08:00] [INFO] [session.go:2418] [GENERAL_LOG] [conn=6] [current_db=other] [sql="select * from t where a = ? [arguments: 3]"]
+`
+	qs, err := parseGeneralLog(strings.NewReader(log), "")
+	c.Assert(err, IsNil)
+	c.Assert(qs, HasLen, 3)
+	c.Assert(qs[0], DeepEquals, Query{Schema: "test", SQL: `select * from t where b = "a]b"`, Digest: qs[0].Digest})
+	c.Assert(qs[0].Digest, Not(Equals), "")
+	c.Assert(qs[1].SQL, Equals, "select")
+	c.Assert(qs[2].SQL, Equals, "select * from t where a = ?")
+	c.Assert(qs[2].Params, DeepEquals, []QueryParam{{Type: paramTypeInt, Value: "3"}})
+
+	qs, err = parseGeneralLog(strings.NewReader(log), "test")
+	c.Assert(err, IsNil)
+	c.Assert(qs, HasLen, 1)
+}
+
+// pcapBuilder writes TCP segments between a client and TiDB into a pcap file.
+type pcapBuilder struct {
+	c         *C
+	w         *pcapgo.Writer
+	clientSeq uint32
+	serverSeq uint32
+}
+
+func (b *pcapBuilder) segment(toServer bool, seq uint32, payload []byte) {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP,
+		SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	tcp := &layers.TCP{SrcPort: 50000, DstPort: 4000, Seq: seq, ACK: true, PSH: true, Window: 1024}
+	if !toServer {
+		ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+		tcp.SrcPort, tcp.DstPort = tcp.DstPort, tcp.SrcPort
+	}
+	c := b.c
+	c.Assert(tcp.SetNetworkLayerForChecksum(ip), IsNil)
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{0, 0, 0, 0, 0, 2}, EthernetType: layers.EthernetTypeIPv4}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	c.Assert(gopacket.SerializeLayers(buf, opts, eth, ip, tcp, gopacket.Payload(payload)), IsNil)
+	data := buf.Bytes()
+	ci := gopacket.CaptureInfo{Timestamp: time.Unix(0, 0), CaptureLength: len(data), Length: len(data)}
+	c.Assert(b.w.WritePacket(ci, data), IsNil)
+}
+
+func (b *pcapBuilder) send(toServer bool, seq byte, payload []byte) {
+	packet := append([]byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}, payload...)
+	if toServer {
+		b.segment(true, b.clientSeq, packet)
+		b.clientSeq += uint32(len(packet))
+	} else {
+		b.segment(false, b.serverSeq, packet)
+		b.serverSeq += uint32(len(packet))
+	}
+}
+
+func (s *loadTestSuite) TestParsePcap(c *C) {
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	c.Assert(w.WriteFileHeader(65536, layers.LinkTypeEthernet), IsNil)
+	b := &pcapBuilder{c: c, w: w, clientSeq: 1000, serverSeq: 5000}
+
+	// handshake response with the database
+	resp := make([]byte, 32)
+	binary.LittleEndian.PutUint32(resp, clientProtocol41|clientSecureConnection|clientConnectWithDB)
+	resp = append(resp, []byte("root\x00")...)
+	resp = append(resp, 0)
+	resp = append(resp, []byte("test\x00mysql_native_password\x00")...)
+	b.send(true, 1, resp)
+
+	b.send(true, 0, append([]byte{comQuery}, "select * from t where a = 1"...))
+	b.send(true, 0, append([]byte{comQuery}, "insert into t values (1)"...))
+	b.send(true, 0, append([]byte{comInitDB}, "other"...))
+	b.send(true, 0, append([]byte{comStmtPrepare}, "select * from t where a = ? and b = '?' and c = ?"...))
+	prepareOK := []byte{0x00, 1, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0}
+	b.send(false, 1, prepareOK)
+
+	execute := []byte{comStmtExecute, 1, 0, 0, 0, 0, 1, 0, 0, 0}
+	execute = append(execute, 0x00, 1)                       // null bitmap and new params bound flag
+	execute = append(execute, mysqlTypeLongLong, 0, 0xfd, 0) // parameter types
+	arg := make([]byte, 8)
+	binary.LittleEndian.PutUint64(arg, uint64(42))
+	execute = append(execute, arg...)
+	execute = append(execute, 3, 'x', '\'', 'y')
+	b.send(true, 0, execute)
+	// a retransmitted segment is ignored
+	b.clientSeq -= uint32(len(execute) + 4)
+	b.send(true, 0, execute)
+
+	qs, err := parsePcap(&buf, 4000, "")
+	c.Assert(err, IsNil)
+	c.Assert(qs, HasLen, 2)
+	c.Assert(qs[0].Schema, Equals, "test")
+	c.Assert(qs[0].SQL, Equals, "select * from t where a = 1")
+	c.Assert(qs[1].Schema, Equals, "other")
+	c.Assert(qs[1].SQL, Equals, "select * from t where a = ? and b = '?' and c = ?")
+	c.Assert(qs[1].Params, DeepEquals, []QueryParam{{Type: paramTypeInt, Value: "42"}, {Type: paramTypeString, Value: "x'y"}})
+	c.Assert(qs[1].explainSQL(), Equals, `select * from t where a = 42 and b = '?' and c = 'x\'y'`)
+
+	param, n, err := readBinaryParam(mysqlTypeDatetime, false, []byte{7, 0xe5, 0x07, 4, 15, 10, 30, 0})
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 8)
+	c.Assert(param, DeepEquals, QueryParam{Type: paramTypeDatetime, Value: "2021-04-15 10:30:00"})
+	c.Assert(param.literal(), Equals, "CAST('2021-04-15 10:30:00' AS DATETIME)")
+	_, _, err = readBinaryParam(mysqlTypeLong, false, []byte{1, 2})
+	c.Assert(err, NotNil)
+}
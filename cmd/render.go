@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+	"github.com/spf13/cobra"
+)
+
+type renderOpt struct {
+	path1   string
+	ver1    string
+	path2   string
+	ver2    string
+	sql     string
+	format  string
+	output  string
+	compare compareOpt
+}
+
+func newRenderCmd() *cobra.Command {
+	var opt renderOpt
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "render plans as DOT or Mermaid diagrams",
+		Long:  `render a plan, or two plans with their differences highlighted, as DOT or Mermaid diagrams`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := renderPlans(opt)
+			if err != nil {
+				return err
+			}
+			if opt.output == "" {
+				fmt.Print(out)
+				return nil
+			}
+			return ioutil.WriteFile(opt.output, []byte(out), 0644)
+		},
+	}
+	cmd.Flags().StringVar(&opt.path1, "path1", "", "file containing the explain result of the first plan")
+	cmd.Flags().StringVar(&opt.ver1, "ver1", plan.V4, "version of the first plan")
+	cmd.Flags().StringVar(&opt.path2, "path2", "", "file containing the explain result of the second plan, the differences are highlighted if it's set")
+	cmd.Flags().StringVar(&opt.ver2, "ver2", plan.V4, "version of the second plan")
+	cmd.Flags().StringVar(&opt.sql, "sql", "", "SQL of the plans")
+	cmd.Flags().StringVar(&opt.format, "format", "dot", "diagram format: dot or mermaid")
+	cmd.Flags().StringVar(&opt.output, "output-file", "", "file to write the diagram into, print it to stdout if it's empty")
+	opt.compare.addFlags(cmd)
+	return cmd
+}
+
+func renderPlans(opt renderOpt) (string, error) {
+	format := strings.ToLower(opt.format)
+	if format != "dot" && format != "mermaid" {
+		return "", fmt.Errorf("unknown diagram format %v, supported formats are dot and mermaid", opt.format)
+	}
+	if opt.path1 == "" {
+		return "", fmt.Errorf("no plan to render, please set --path1")
+	}
+	p1, err := readPlanFile(opt.sql, opt.path1, opt.ver1)
+	if err != nil {
+		return "", err
+	}
+	if opt.path2 == "" {
+		if format == "dot" {
+			return plan.ToDOT(p1), nil
+		}
+		return plan.ToMermaid(p1), nil
+	}
+
+	p2, err := readPlanFile(opt.sql, opt.path2, opt.ver2)
+	if err != nil {
+		return "", err
+	}
+	compareOpts, err := opt.compare.options()
+	if err != nil {
+		return "", err
+	}
+	diff := plan.CompareDetailed(p1, p2, compareOpts...)
+	if format == "dot" {
+		return plan.DiffToDOT(p1, p2, diff), nil
+	}
+	return plan.DiffToMermaid(p1, p2, diff), nil
+}
+
+// readPlanFile parses the explain result in the file.
+func readPlanFile(sql, path, version string) (plan.Plan, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	p, err := plan.ParseText(sql, string(content), version)
+	if err != nil {
+		return plan.Plan{}, fmt.Errorf("parse plan in %v error: %v", path, err)
+	}
+	return p, nil
+}
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// reportOpt contains options about how compare results are output.
+type reportOpt struct {
+	format string
+	file   string
+	// planTrees keeps the parsed plans in results, they are only needed to compare result files offline.
+	planTrees bool
+}
+
+func (opt *reportOpt) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&opt.format, "output-format", "text",
+		fmt.Sprintf("format of the compare results: %v", strings.Join(reportFormats(), " / ")))
+	cmd.Flags().StringVar(&opt.file, "output-file", "", "file to write the compare results, the results are printed to stdout if it's empty and other output is sent to stderr unless the format is text")
+	cmd.Flags().BoolVar(&opt.planTrees, "with-plan-trees", false, "write the parsed plans into JSON results, which are required to compare result files offline by the diff command")
+}
+
+// reportWriters are all supported report formats.
+var reportWriters = map[string]func(w io.Writer, result PlanCompareResult) error{
+	"text":     writeTextReport,
+	"json":     writeJSONReport,
+	"csv":      writeCSVReport,
+	"junit":    writeJUnitReport,
+	"html":     writeHTMLReport,
+	"markdown": writeMarkdownReport,
+}
+
+func reportFormats() []string {
+	formats := make([]string, 0, len(reportWriters))
+	for f := range reportWriters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// check returns an error if the format is unknown, it's called before running to fail fast.
+func (opt *reportOpt) check() error {
+	_, err := opt.writer()
+	return err
+}
+
+func (opt *reportOpt) writer() (func(w io.Writer, result PlanCompareResult) error, error) {
+	write, ok := reportWriters[strings.ToLower(opt.format)]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %v, available formats: %v", opt.format, strings.Join(reportFormats(), ", "))
+	}
+	return write, nil
+}
+
+// logOut returns where progress and other messages are printed, they are sent to stderr
+// if a report other than text is written to stdout so that the report can be parsed.
+func (opt *reportOpt) logOut() io.Writer {
+	if opt.file == "" && strings.ToLower(opt.format) != "text" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// writeReport writes the compare results into the output file or stdout.
+func writeReport(opt reportOpt, result PlanCompareResult) error {
+	write, err := opt.writer()
+	if err != nil {
+		return err
+	}
+	if !opt.planTrees {
+		result = withoutPlanTrees(result)
+	}
+	if opt.file == "" {
+		return write(os.Stdout, result)
+	}
+	f, err := os.Create(opt.file)
+	if err != nil {
+		return fmt.Errorf("create output file %v error: %v", opt.file, err)
+	}
+	if err := write(f, result); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// withoutPlanTrees returns a copy of the result whose parsed plans are removed.
+func withoutPlanTrees(result PlanCompareResult) PlanCompareResult {
+	rs := make([]SinglePlanCompareResult, len(result.Results))
+	for i, r := range result.Results {
+		r.OldPlanTree, r.NewPlanTree = nil, nil
+		rs[i] = r
+	}
+	result.Results = rs
+	return result
+}
+
+func writeTextReport(w io.Writer, result PlanCompareResult) error {
+	for _, r := range result.Results {
+		printCompareResult(w, r)
+	}
+	printSummary(w, result.Summary)
+	return nil
+}
+
+func writeJSONReport(w io.Writer, result PlanCompareResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&result)
+}
+
+var csvHeader = []string{"sql", "digest", "schema", "same", "severity", "similarity", "reason", "differences",
+	"max_est_rows_drift", "latency_regressed", "old_binding", "new_binding", "old_plan", "new_plan"}
+
+func writeCSVReport(w io.Writer, result PlanCompareResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range result.Results {
+		diffs := make([]string, 0, len(r.Diffs))
+		for _, d := range r.Diffs {
+			diffs = append(diffs, d.String())
+		}
+		record := []string{r.SQL, r.Digest, r.Schema, strconv.FormatBool(r.Same), r.Severity.String(),
+			strconv.FormatFloat(r.Similarity, 'f', 4, 64), r.Reason, strings.Join(diffs, "\n"),
+			strconv.FormatFloat(r.MaxEstRowsDrift, 'f', 2, 64), strconv.FormatBool(r.LatencyRegressed),
+			strconv.FormatBool(r.OldBinding), strconv.FormatBool(r.NewBinding), r.OldPlan, r.NewPlan}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// writeJUnitReport reports each query as a test case, which fails if its plan changes or its latency regresses,
+// unchanged queries are passing test cases.
+func writeJUnitReport(w io.Writer, result PlanCompareResult) error {
+	suite := junitTestSuite{Name: "plan-change-capturer"}
+	for _, r := range result.Results {
+		var details strings.Builder
+		printCompareResult(&details, r)
+		tc := junitTestCase{Name: r.SQL, ClassName: r.Schema}
+		if !r.Same || r.LatencyRegressed || r.BindingLost {
+			suite.Failures++
+			msg := r.Reason
+			if r.Same && r.LatencyRegressed {
+				msg = "latency regressed"
+			} else if r.Same {
+				msg = "binding lost"
+			}
+			tc.Failure = &junitFailure{Message: msg, Type: r.Severity.String(), Content: details.String()}
+		} else {
+			tc.SystemOut = details.String()
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	for _, q := range result.Unchanged {
+		suite.Cases = append(suite.Cases, junitTestCase{Name: q.SQL, ClassName: q.Schema})
+	}
+	suite.Tests = result.Summary.Total
+	if suite.Tests < len(suite.Cases) {
+		suite.Tests = len(suite.Cases)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// printCompareResult prints a changed plan and all its differences.
+func printCompareResult(w io.Writer, r SinglePlanCompareResult) {
+	fmt.Fprintln(w, "=====================================================================")
+	fmt.Fprintln(w, "SQL: ")
+	fmt.Fprintln(w, r.SQL)
+	fmt.Fprintln(w)
+	if r.OldPlanExplained {
+		fmt.Fprintln(w, "Plan1 (no recorded plan, explained on the first TiDB): ")
+	} else {
+		fmt.Fprintln(w, "Plan1: ")
+	}
+	fmt.Fprintln(w, r.OldPlan)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Plan2: ")
+	fmt.Fprintln(w, r.NewPlan)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Reason: ", r.Reason)
+	fmt.Fprintln(w, "Severity: ", r.Severity)
+	fmt.Fprintf(w, "Similarity: %.2f\n", r.Similarity)
+	if len(r.Diffs) > 0 {
+		fmt.Fprintln(w, "Differences: ")
+		for _, d := range r.Diffs {
+			fmt.Fprintln(w, "  "+d.String())
+		}
+	}
+	if len(r.EstRowsDrifts) > 0 {
+		fmt.Fprintf(w, "Estimated Rows Drifts (max %.2fx): \n", r.MaxEstRowsDrift)
+		for _, d := range r.EstRowsDrifts {
+			fmt.Fprintln(w, "  "+d.String())
+		}
+	}
+	if r.Runtime != nil {
+		fmt.Fprintln(w, "Runtime: ", r.Runtime.String())
+		if r.LatencyRegressed {
+			fmt.Fprintln(w, "Latency Regressed: true")
+		}
+	}
+	if r.OldBinding || r.NewBinding {
+		fmt.Fprintln(w, "Binding: ", bindingStatus(r))
+	}
+	fmt.Fprintln(w, "=====================================================================")
+}
+
+// bindingStatus describes whether the plans are produced under bindings.
+func bindingStatus(r SinglePlanCompareResult) string {
+	bound := func(b bool) string {
+		if b {
+			return "bound"
+		}
+		return "not bound"
+	}
+	status := fmt.Sprintf("plan1 %v, plan2 %v", bound(r.OldBinding), bound(r.NewBinding))
+	if r.BindingLost {
+		status += ", the binding doesn't apply in the new version"
+	}
+	return status
+}
+
+// CompareSummary aggregates the compare results of all queries.
+type CompareSummary struct {
+	Total           int     `json:"total"`
+	Changed         int     `json:"changed"`
+	EstRowsDrifted  int     `json:"estRowsDrifted"`
+	MaxEstRowsDrift float64 `json:"maxEstRowsDrift"`
+	// LatencyRegressed is the number of queries whose latency regressed in EXPLAIN ANALYZE mode.
+	LatencyRegressed int `json:"latencyRegressed"`
+	// BindingLost is the number of queries whose bindings don't apply in the new version.
+	BindingLost int `json:"bindingLost"`
+	// Errored is the number of queries failed to explain, they are not counted in Total.
+	Errored int `json:"errored"`
+	// OldPlanExplained is the number of queries without recorded plans in --recorded-plans mode.
+	OldPlanExplained int `json:"oldPlanExplained,omitempty"`
+}
+
+func summarizeResults(rs []SinglePlanCompareResult) CompareSummary {
+	s := CompareSummary{Total: len(rs)}
+	for _, r := range rs {
+		if !r.Same {
+			s.Changed++
+		}
+		if len(r.EstRowsDrifts) > 0 {
+			s.EstRowsDrifted++
+		}
+		if r.LatencyRegressed {
+			s.LatencyRegressed++
+		}
+		if r.BindingLost {
+			s.BindingLost++
+		}
+		if r.MaxEstRowsDrift > s.MaxEstRowsDrift {
+			s.MaxEstRowsDrift = r.MaxEstRowsDrift
+		}
+	}
+	return s
+}
+
+func printSummary(w io.Writer, s CompareSummary) {
+	fmt.Fprintf(w, "compared %v queries, %v plans changed, %v plans have estimated rows drifts", s.Total, s.Changed, s.EstRowsDrifted)
+	if s.EstRowsDrifted > 0 {
+		fmt.Fprintf(w, " (max %.2fx)", s.MaxEstRowsDrift)
+	}
+	if s.LatencyRegressed > 0 {
+		fmt.Fprintf(w, ", %v queries have latency regressions", s.LatencyRegressed)
+	}
+	if s.BindingLost > 0 {
+		fmt.Fprintf(w, ", %v queries lost their bindings", s.BindingLost)
+	}
+	if s.Errored > 0 {
+		fmt.Fprintf(w, ", %v queries failed", s.Errored)
+	}
+	if s.OldPlanExplained > 0 {
+		fmt.Fprintf(w, ", %v queries have no recorded plans and their old plans are explained", s.OldPlanExplained)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+type htmlReport struct {
+	PlanCompareResult
+	Schemas    []string
+	Severities []string
+	// SeverityCounts is the number of queries of each severity in Severities.
+	SeverityCounts []int
+	Queries        []htmlQuery
+}
+
+type htmlQuery struct {
+	SinglePlanCompareResult
+	OldLines []htmlPlanLine
+	NewLines []htmlPlanLine
+}
+
+type htmlPlanLine struct {
+	Text string
+	Diff bool
+}
+
+// writeHTMLReport renders changed queries with their plans side by side, lines of differing operators are highlighted.
+func writeHTMLReport(w io.Writer, result PlanCompareResult) error {
+	report := htmlReport{PlanCompareResult: result}
+	schemas := make(map[string]struct{})
+	counts := make(map[plan.Severity]int)
+	for _, r := range result.Results {
+		if r.Same && len(r.EstRowsDrifts) == 0 && !r.LatencyRegressed && !r.BindingLost {
+			continue
+		}
+		schemas[r.Schema] = struct{}{}
+		counts[r.Severity]++
+
+		oldRows, newRows := plan.DiffRows(r.Diffs)
+		report.Queries = append(report.Queries, htmlQuery{
+			SinglePlanCompareResult: r,
+			OldLines:                markPlanLines(r.OldPlan, oldRows),
+			NewLines:                markPlanLines(r.NewPlan, newRows),
+		})
+	}
+	for s := range schemas {
+		report.Schemas = append(report.Schemas, s)
+	}
+	sort.Strings(report.Schemas)
+	for _, sev := range []plan.Severity{plan.SeverityCritical, plan.SeverityWarning, plan.SeverityInfo, plan.SeverityNone} {
+		report.Severities = append(report.Severities, sev.String())
+		report.SeverityCounts = append(report.SeverityCounts, counts[sev])
+	}
+	return htmlReportTemplate.Execute(w, report)
+}
+
+// markPlanLines splits the plan text into lines and marks lines of the differing operators,
+// which are located by their rows since operator IDs may be duplicated.
+func markPlanLines(planText string, rows map[int]struct{}) []htmlPlanLine {
+	var lines []htmlPlanLine
+	row := 0
+	for _, line := range strings.Split(strings.Trim(planText, "\n"), "\n") {
+		diff := false
+		if !isPlanBoundary(line) && !isPlanHeader(line) {
+			_, diff = rows[row]
+			row++
+		}
+		lines = append(lines, htmlPlanLine{Text: line, Diff: diff})
+	}
+	return lines
+}
+
+// isPlanHeader returns whether the line is the header of the plan, whose first column is "id".
+func isPlanHeader(line string) bool {
+	fields := strings.FieldsFunc(line, func(r rune) bool { return r == '|' || r == '\t' })
+	return len(fields) > 0 && strings.EqualFold(strings.TrimSpace(fields[0]), "id")
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{"bindingStatus": bindingStatus}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Plan Change Report</title>
+<style>
+body { font-family: sans-serif; margin: 20px; }
+table.summary { border-collapse: collapse; }
+table.summary td, table.summary th { border: 1px solid #ccc; padding: 4px 12px; text-align: left; }
+.filters { margin: 16px 0; }
+.query { border: 1px solid #ccc; border-radius: 4px; margin: 12px 0; padding: 8px 12px; }
+.query h3 { margin: 4px 0; }
+.severity-critical { color: #c00; }
+.severity-warning { color: #c60; }
+.severity-info { color: #06c; }
+.plans { display: flex; gap: 12px; }
+.plan { flex: 1; overflow-x: auto; }
+pre { background: #f6f8fa; padding: 8px; margin: 4px 0; }
+pre .diff { background: #ffd7d5; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Plan Change Report</h1>
+{{if .NewVersion}}<p>New version: {{.NewVersion}}</p>{{end}}
+<table class="summary">
+<tr><th>Compared queries</th><td>{{.Summary.Total}}</td></tr>
+<tr><th>Changed plans</th><td>{{.Summary.Changed}}</td></tr>
+<tr><th>Estimated rows drifts</th><td>{{.Summary.EstRowsDrifted}}</td></tr>
+<tr><th>Latency regressions</th><td>{{.Summary.LatencyRegressed}}</td></tr>
+<tr><th>Bindings lost</th><td>{{.Summary.BindingLost}}</td></tr>
+{{range $i, $sev := .Severities}}<tr><th>Severity {{$sev}}</th><td>{{index $.SeverityCounts $i}}</td></tr>
+{{end}}</table>
+<div class="filters">
+Schema: <select id="schema-filter" onchange="filterQueries()"><option value="">all</option>{{range .Schemas}}<option value="{{.}}">{{.}}</option>{{end}}</select>
+Severity: <select id="severity-filter" onchange="filterQueries()"><option value="">all</option>{{range .Severities}}<option value="{{.}}">{{.}}</option>{{end}}</select>
+</div>
+{{range $i, $q := .Queries}}<div class="query" data-schema="{{$q.Schema}}" data-severity="{{$q.Severity}}">
+<h3><span class="severity-{{$q.Severity}}">[{{$q.Severity}}]</span> #{{$i}} {{if $q.Schema}}{{$q.Schema}}{{end}}</h3>
+<pre>{{$q.SQL}}</pre>
+{{if $q.Reason}}<p><b>Reason:</b> {{$q.Reason}}</p>{{end}}
+<p><b>Similarity:</b> {{printf "%.2f" $q.Similarity}}{{if $q.LatencyRegressed}} <b class="severity-critical">latency regressed</b>{{end}}</p>
+{{if $q.Diffs}}<ul>{{range $q.Diffs}}<li>{{.String}}</li>{{end}}</ul>{{end}}
+{{if $q.EstRowsDrifts}}<p><b>Estimated rows drifts:</b></p><ul>{{range $q.EstRowsDrifts}}<li>{{.String}}</li>{{end}}</ul>{{end}}
+{{if $q.Runtime}}<p><b>Runtime:</b> {{$q.Runtime.String}}</p>{{end}}
+{{if or $q.OldBinding $q.NewBinding}}<p><b>Binding:</b> {{bindingStatus $q.SinglePlanCompareResult}}</p>{{end}}
+<div class="plans">
+<div class="plan"><h4>Plan1</h4><pre>{{range $q.OldLines}}{{if .Diff}}<span class="diff">{{.Text}}</span>{{else}}{{.Text}}{{end}}
+{{end}}</pre></div>
+<div class="plan"><h4>Plan2</h4><pre>{{range $q.NewLines}}{{if .Diff}}<span class="diff">{{.Text}}</span>{{else}}{{.Text}}{{end}}
+{{end}}</pre></div>
+</div>
+{{if $q.Diagram}}<details><summary>Mermaid diagram</summary><pre class="mermaid">{{$q.Diagram}}</pre></details>{{end}}
+</div>
+{{end}}<script>
+function filterQueries() {
+  var schema = document.getElementById("schema-filter").value;
+  var severity = document.getElementById("severity-filter").value;
+  document.querySelectorAll(".query").forEach(function (q) {
+    var visible = (!schema || q.dataset.schema === schema) && (!severity || q.dataset.severity === severity);
+    q.style.display = visible ? "" : "none";
+  });
+}
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+// writeMarkdownReport writes a summary table and a collapsible section for each changed query,
+// which can be posted into pull requests or tickets directly.
+func writeMarkdownReport(w io.Writer, result PlanCompareResult) error {
+	var changed []SinglePlanCompareResult
+	categories := make(map[plan.DiffKind]int)
+	for _, r := range result.Results {
+		if r.Same && len(r.EstRowsDrifts) == 0 && !r.LatencyRegressed && !r.BindingLost {
+			continue
+		}
+		changed = append(changed, r)
+		// a query is counted once for each kind of its differences
+		kinds := make(map[plan.DiffKind]struct{})
+		for _, d := range r.Diffs {
+			kinds[d.Kind] = struct{}{}
+		}
+		for k := range kinds {
+			categories[k]++
+		}
+	}
+
+	s := result.Summary
+	var b strings.Builder
+	b.WriteString("## Plan Change Report\n\n")
+	if result.NewVersion != "" {
+		fmt.Fprintf(&b, "New version: `%v`\n\n", result.NewVersion)
+	}
+	b.WriteString("| Item | Count |\n| --- | --- |\n")
+	fmt.Fprintf(&b, "| Total | %v |\n", s.Total+s.Errored)
+	fmt.Fprintf(&b, "| Same | %v |\n", s.Total-s.Changed)
+	fmt.Fprintf(&b, "| Changed | %v |\n", s.Changed)
+	fmt.Fprintf(&b, "| Errored | %v |\n", s.Errored)
+	if s.EstRowsDrifted > 0 {
+		fmt.Fprintf(&b, "| Estimated rows drifted | %v |\n", s.EstRowsDrifted)
+	}
+	if s.LatencyRegressed > 0 {
+		fmt.Fprintf(&b, "| Latency regressed | %v |\n", s.LatencyRegressed)
+	}
+	if s.BindingLost > 0 {
+		fmt.Fprintf(&b, "| Binding lost | %v |\n", s.BindingLost)
+	}
+
+	if len(categories) > 0 {
+		kinds := make([]string, 0, len(categories))
+		for k := range categories {
+			kinds = append(kinds, string(k))
+		}
+		sort.Strings(kinds)
+		b.WriteString("\n| Reason category | Queries |\n| --- | --- |\n")
+		for _, k := range kinds {
+			fmt.Fprintf(&b, "| `%v` | %v |\n", k, categories[plan.DiffKind(k)])
+		}
+	}
+
+	for i, r := range changed {
+		title := r.Reason
+		if title == "" && r.LatencyRegressed {
+			title = "latency regressed"
+		} else if title == "" && r.BindingLost {
+			title = "binding lost"
+		} else if title == "" {
+			title = "estimated rows drifted"
+		}
+		fmt.Fprintf(&b, "\n<details>\n<summary>#%v [%v] %v</summary>\n\n", i, r.Severity, markdownEscape(title))
+		if r.Schema != "" {
+			fmt.Fprintf(&b, "Schema: `%v`\n\n", r.Schema)
+		}
+		fmt.Fprintf(&b, "```sql\n%v\n```\n\n", strings.TrimSpace(r.SQL))
+		if r.Reason != "" {
+			fmt.Fprintf(&b, "**Reason:** %v\n\n", markdownEscape(r.Reason))
+		}
+		for _, d := range r.Diffs {
+			fmt.Fprintf(&b, "- %v\n", markdownEscape(d.String()))
+		}
+		for _, d := range r.EstRowsDrifts {
+			fmt.Fprintf(&b, "- %v\n", markdownEscape(d.String()))
+		}
+		if r.Runtime != nil {
+			fmt.Fprintf(&b, "- %v\n", markdownEscape(r.Runtime.String()))
+		}
+		if r.OldBinding || r.NewBinding {
+			fmt.Fprintf(&b, "- binding: %v\n", markdownEscape(bindingStatus(r)))
+		}
+		fmt.Fprintf(&b, "\nPlan1:\n\n```\n%v\n```\n\n", strings.Trim(r.OldPlan, "\n"))
+		fmt.Fprintf(&b, "Plan2:\n\n```\n%v\n```\n\n", strings.Trim(r.NewPlan, "\n"))
+		if r.Diagram != "" {
+			fmt.Fprintf(&b, "```mermaid\n%v```\n\n", r.Diagram)
+		}
+		b.WriteString("</details>\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer("|", "\\|", "<", "&lt;", ">", "&gt;", "*", "\\*", "_", "\\_", "`", "\\`")
+
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+const reportTestPlan1 = `
++-------------------------+----------+-----------+---------------------+-------------------------------+
+| id                      | estRows  | task      | access object       | operator info                 |
++-------------------------+----------+-----------+---------------------+-------------------------------+
+| IndexLookUp_10          | 10.00    | root      |                     |                               |
+| ├─IndexRangeScan_8(Build) | 10.00  | cop[tikv] | table:t, index:b(b) | range:[10,10], keep order:false |
+| └─TableRowIDScan_9(Probe) | 10.00  | cop[tikv] | table:t             | keep order:false              |
++-------------------------+----------+-----------+---------------------+-------------------------------+
+`
+
+const reportTestPlan2 = `
++-------------------------+----------+-----------+---------------------+-------------------------------+
+| id                      | estRows  | task      | access object       | operator info                 |
++-------------------------+----------+-----------+---------------------+-------------------------------+
+| TableReader_7           | 10.00    | root      |                     | data:Selection_6              |
+| └─Selection_6           | 10.00    | cop[tikv] |                     | eq(test.t.b, 10)              |
+|   └─TableFullScan_5     | 10000.00 | cop[tikv] | table:t             | keep order:false              |
++-------------------------+----------+-----------+---------------------+-------------------------------+
+`
+
+func reportTestResult(c *C) PlanCompareResult {
+	sql := "explain select * from t where b=10"
+	changed, err := check(sql, plan.V4, reportTestPlan1, plan.V4, reportTestPlan2)
+	c.Assert(err, IsNil)
+	c.Assert(changed, NotNil)
+	same, err := check(sql, plan.V4, reportTestPlan1, plan.V4, reportTestPlan1)
+	c.Assert(err, IsNil)
+	c.Assert(same, IsNil)
+
+	results := []SinglePlanCompareResult{*changed}
+	unchanged := []UnchangedQuery{{SQL: sql, Schema: "test"}}
+	result := PlanCompareResult{NewVersion: plan.V4, Summary: summarizeResults(results), Results: results, Unchanged: unchanged}
+	result.Summary.Total = 2
+	return result
+}
+
+func (s *loadTestSuite) TestReportFormats(c *C) {
+	result := reportTestResult(c)
+
+	var buf bytes.Buffer
+	c.Assert(writeTextReport(&buf, result), IsNil)
+	c.Assert(strings.Contains(buf.String(), "different operators IndexLookUp_10 and TableReader_7"), IsTrue)
+	c.Assert(strings.Contains(buf.String(), "compared 2 queries, 1 plans changed"), IsTrue)
+	c.Assert(strings.Contains(buf.String(), "no recorded plan"), IsFalse)
+
+	explained := result
+	explained.Results = []SinglePlanCompareResult{result.Results[0]}
+	explained.Results[0].OldPlanExplained = true
+	explained.Summary.OldPlanExplained = 2
+	buf.Reset()
+	c.Assert(writeTextReport(&buf, explained), IsNil)
+	c.Assert(strings.Contains(buf.String(), "Plan1 (no recorded plan, explained on the first TiDB)"), IsTrue)
+	c.Assert(strings.Contains(buf.String(), "2 queries have no recorded plans"), IsTrue)
+
+	buf.Reset()
+	c.Assert(writeJSONReport(&buf, result), IsNil)
+	var decoded PlanCompareResult
+	c.Assert(json.Unmarshal(buf.Bytes(), &decoded), IsNil)
+	c.Assert(decoded.Summary, Equals, result.Summary)
+	c.Assert(decoded.Results, HasLen, 1)
+	c.Assert(decoded.Results[0].Severity, Equals, result.Results[0].Severity)
+
+	buf.Reset()
+	c.Assert(writeCSVReport(&buf, result), IsNil)
+	records, err := csv.NewReader(&buf).ReadAll()
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 2)
+	c.Assert(records[0], DeepEquals, csvHeader)
+	c.Assert(records[1][0], Equals, result.Results[0].SQL)
+	c.Assert(records[1][3], Equals, "false")
+
+	buf.Reset()
+	c.Assert(writeJUnitReport(&buf, result), IsNil)
+	var suites junitTestSuites
+	c.Assert(xml.Unmarshal(buf.Bytes(), &suites), IsNil)
+	c.Assert(suites.Suites, HasLen, 1)
+	c.Assert(suites.Suites[0].Tests, Equals, 2)
+	c.Assert(suites.Suites[0].Failures, Equals, 1)
+	c.Assert(suites.Suites[0].Cases, HasLen, 2)
+	c.Assert(suites.Suites[0].Cases[0].Failure.Message, Equals, result.Results[0].Reason)
+	c.Assert(suites.Suites[0].Cases[1].Failure, IsNil)
+	c.Assert(suites.Suites[0].Cases[1].ClassName, Equals, "test")
+
+	path := filepath.Join(c.MkDir(), "result.json")
+	c.Assert(writeReport(reportOpt{format: "JSON", file: path}, result), IsNil)
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(json.Unmarshal(data, &decoded), IsNil)
+
+	c.Assert(writeReport(reportOpt{format: "yaml"}, result), NotNil)
+}
+
+func (s *loadTestSuite) TestReportLogOut(c *C) {
+	stdout := os.Stdout
+	opt := &reportOpt{format: "text"}
+	c.Assert(opt.logOut(), Equals, stdout)
+	opt = &reportOpt{format: "json", file: "result.json"}
+	c.Assert(opt.logOut(), Equals, stdout)
+	// logs are sent to stderr to keep the report on stdout parsable
+	opt = &reportOpt{format: "junit"}
+	c.Assert(opt.check(), IsNil)
+	c.Assert(opt.logOut(), Equals, os.Stderr)
+	c.Assert(os.Stdout, Equals, stdout)
+}
+
+func (s *loadTestSuite) TestHTMLReport(c *C) {
+	result := reportTestResult(c)
+	result.Results[0].Schema = "test"
+	result.Results = append(result.Results, SinglePlanCompareResult{SQL: "select 1", Schema: "same_schema", Same: true})
+
+	var buf bytes.Buffer
+	c.Assert(writeHTMLReport(&buf, result), IsNil)
+	html := buf.String()
+	c.Assert(strings.Contains(html, `<div class="query" data-schema="test" data-severity="warning">`), IsTrue)
+	c.Assert(strings.Contains(html, `<span class="diff">| IndexLookUp_10 `), IsTrue)
+	c.Assert(strings.Contains(html, `<span class="diff">| TableReader_7 `), IsTrue)
+	c.Assert(strings.Contains(html, `<span class="diff">| └─TableFullScan_5`), IsFalse)
+	c.Assert(strings.Contains(html, `<option value="test">test</option>`), IsTrue)
+	// unchanged queries are not rendered
+	c.Assert(strings.Contains(html, "same_schema"), IsFalse)
+
+	// lines are located by rows, the other operator with the same ID is not marked
+	lines := markPlanLines(`
++--------------------+---------+
+| id                 | estRows |
++--------------------+---------+
+| TableReader        | 10.00   |
+| └─ExchangeSender   | 10.00   |
+|   └─ExchangeSender | 10.00   |
++--------------------+---------+
+`, map[int]struct{}{2: {}})
+	c.Assert(lines, HasLen, 7)
+	for i, line := range lines {
+		c.Assert(line.Diff, Equals, i == 5)
+	}
+	c.Assert(strings.Contains(html, `<pre class="mermaid">graph TD`), IsTrue)
+}
+
+func (s *loadTestSuite) TestMarkdownReport(c *C) {
+	result := reportTestResult(c)
+	result.Summary.Errored = 1
+
+	var buf bytes.Buffer
+	c.Assert(writeMarkdownReport(&buf, result), IsNil)
+	md := buf.String()
+	c.Assert(strings.Contains(md, "| Total | 3 |\n| Same | 1 |\n| Changed | 1 |\n| Errored | 1 |\n"), IsTrue)
+	c.Assert(strings.Contains(md, "| `access_path` | 1 |"), IsTrue)
+	c.Assert(strings.Contains(md, "<summary>#0 [warning] different operators IndexLookUp\\_10 and TableReader\\_7</summary>"), IsTrue)
+	c.Assert(strings.Contains(md, "| IndexLookUp_10 "), IsTrue)
+	c.Assert(strings.Count(md, "<details>"), Equals, 1)
+	c.Assert(strings.Count(md, "</details>"), Equals, 1)
+	c.Assert(strings.Contains(md, "```mermaid\ngraph TD\n"), IsTrue)
+}
+
+func (s *loadTestSuite) TestRenderPlans(c *C) {
+	dir := c.MkDir()
+	path1, path2 := filepath.Join(dir, "plan1.txt"), filepath.Join(dir, "plan2.txt")
+	c.Assert(ioutil.WriteFile(path1, []byte(reportTestPlan1), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(path2, []byte(reportTestPlan2), 0644), IsNil)
+
+	out, err := renderPlans(renderOpt{path1: path1, ver1: plan.V4, format: "dot"})
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(out, "digraph plan {"), IsTrue)
+	c.Assert(strings.Contains(out, `table:t, index:b\nestRows`), IsTrue)
+
+	out, err = renderPlans(renderOpt{path1: path1, ver1: plan.V4, path2: path2, ver2: plan.V4, format: "Mermaid"})
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(out, "subgraph plan2"), IsTrue)
+	c.Assert(strings.Contains(out, "class a0,b0 diff"), IsTrue)
+
+	_, err = renderPlans(renderOpt{path1: path1, ver1: plan.V4, format: "svg"})
+	c.Assert(err, NotNil)
+	_, err = renderPlans(renderOpt{format: "dot"})
+	c.Assert(err, NotNil)
+}
@@ -26,4 +26,7 @@ func init() {
 	rootCmd.AddCommand(newCaptureCmd())
 	rootCmd.AddCommand(newCheckCmd())
 	rootCmd.AddCommand(newLoadCmd())
+	rootCmd.AddCommand(newRenderCmd())
+	rootCmd.AddCommand(newBaselineCmd())
+	rootCmd.AddCommand(newDiffCmd())
 }
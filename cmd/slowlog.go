@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pingcap/tidb/util/plancodec"
+)
+
+const (
+	slowLogTimePrefix = "# Time: "
+	slowLogTime       = "Time"
+	slowLogDB         = "DB"
+	slowLogQuery      = "Query"
+	slowLogDigest     = "Digest"
+	slowLogPlan       = "Plan"
+	slowLogBinaryPlan = "Binary_plan"
+	slowLogPlanDigest = "Plan_digest"
+	slowLogInternal   = "Is_internal"
+	slowLogFromBind   = "Plan_from_binding"
+)
+
+// slowLogMaxLineSize is the max size of a line in slow log files, queries may be very long.
+const slowLogMaxLineSize = 64 * 1024 * 1024
+
+func runExportSlowLog(opt *exportOpt) error {
+	return exportQueriesFromFiles(opt, "slow log", opt.slowLogFiles, func(r io.Reader) ([]Query, error) {
+		return parseSlowLog(r, opt.specDB)
+	})
+}
+
+// slowLogEntry is a query record in the slow log.
+type slowLogEntry struct {
+	fields map[string]string
+	lines  []string
+}
+
+// parseSlowLog reads SELECT queries in the slow log, internal queries and queries of other DBs than specDB are ignored.
+func parseSlowLog(r io.Reader, specDB string) ([]Query, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), slowLogMaxLineSize)
+	var qs []Query
+	var entry *slowLogEntry
+	flush := func() error {
+		if entry == nil {
+			return nil
+		}
+		q, ok, err := entry.query()
+		entry = nil
+		if err != nil {
+			return err
+		}
+		if ok && (specDB == "" || strings.EqualFold(q.Schema, specDB)) {
+			qs = append(qs, q)
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, slowLogTimePrefix) {
+			if err := flush(); err != nil {
+				return qs, err
+			}
+			entry = &slowLogEntry{fields: make(map[string]string)}
+		}
+		if entry == nil {
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			entry.addFields(line[len("# "):])
+			continue
+		}
+		entry.lines = append(entry.lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return qs, err
+	}
+	return qs, flush()
+}
+
+// addFields reads fields in a line, a line like `Plan_from_cache: false Plan_from_binding: true` contains
+// multiple fields, others are read as one field since their values may contain spaces.
+func (e *slowLogEntry) addFields(line string) {
+	tokens := strings.Fields(line)
+	multiple := len(tokens) > 2 && len(tokens)%2 == 0
+	for i := 0; multiple && i < len(tokens); i += 2 {
+		multiple = strings.HasSuffix(tokens[i], ":") && !strings.HasSuffix(tokens[i+1], ":")
+	}
+	if multiple {
+		for i := 0; i < len(tokens); i += 2 {
+			e.fields[strings.TrimSuffix(tokens[i], ":")] = tokens[i+1]
+		}
+		return
+	}
+	kv := strings.SplitN(line, ": ", 2)
+	if len(kv) == 2 {
+		e.fields[kv[0]] = strings.TrimSpace(kv[1])
+	}
+}
+
+// query returns false if the entry is not a SELECT query issued by users.
+func (e *slowLogEntry) query() (Query, bool, error) {
+	if strings.EqualFold(e.fields[slowLogInternal], "true") {
+		return Query{}, false, nil
+	}
+	schema := e.fields[slowLogDB]
+	var sqlLines []string
+	for _, line := range e.lines {
+		trimmed := strings.TrimSpace(line)
+		if len(sqlLines) == 0 && matchPrefixCaseInsensitive(trimmed, "use ") && strings.HasSuffix(trimmed, ";") {
+			if schema == "" {
+				schema = strings.Trim(strings.TrimSpace(trimmed[len("use "):len(trimmed)-1]), "`")
+			}
+			continue
+		}
+		sqlLines = append(sqlLines, line)
+	}
+	sql := strings.TrimSuffix(strings.TrimSpace(strings.Join(sqlLines, "\n")), ";")
+	if sql == "" {
+		sql = e.fields[slowLogQuery]
+	}
+	q, ok, err := newSelectQuery(schema, sql, e.fields[slowLogDigest])
+	if err != nil || !ok {
+		return Query{}, false, err
+	}
+	q.PlanDigest = e.fields[slowLogPlanDigest]
+	q.PlanFromBinding = strings.EqualFold(e.fields[slowLogFromBind], "true")
+	encoded := e.fields[slowLogPlan]
+	if encoded == "" && e.fields[slowLogBinaryPlan] != "" {
+		// the binary format can only be decoded by tidb_decode_binary_plan of TiDB
+		return Query{}, false, fmt.Errorf("the slow query at %v only has a binary plan, which is not supported, set tidb_generate_binary_plan to OFF to log plans in the text format", e.fields[slowLogTime])
+	}
+	if encoded != "" {
+		// the plan is recorded as tidb_decode_plan('...')
+		encoded = strings.TrimSuffix(strings.TrimPrefix(encoded, "tidb_decode_plan('"), "')")
+		planText, err := plancodec.DecodePlan(encoded)
+		if err != nil {
+			return Query{}, false, fmt.Errorf("decode the plan of the slow query at %v error: %v", e.fields[slowLogTime], err)
+		}
+		q.Plan = planText
+	}
+	return q, true, nil
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/util/plancodec"
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+func (s *loadTestSuite) TestParseSlowLog(c *C) {
+	var buf bytes.Buffer
+	plancodec.EncodePlanNode(0, 0, "TableReader", 10000, plancodec.EncodeTaskType(true, 0), "data:TableFullScan_5", "", "", "", "", &buf)
+	plancodec.EncodePlanNode(1, 0, "TableFullScan", 10000, plancodec.EncodeTaskType(false, 0), "table:t, keep order:false", "", "", "", "", &buf)
+	encodedPlan := plancodec.Compress(buf.Bytes())
+
+	slowLog := `# Time: 2021-04-15T10:00:00.000000+08:00
+# Txn_start_ts: 424829520071688193
+# Query_time: 1.527627037
+# Parse_time: 0.000054933 Compile_time: 0.000129729
+# DB: test
+# Is_internal: false
+# Digest: d1
+# Plan_digest: p1
+# Plan_from_cache: false Plan_from_binding: true
+# Plan: tidb_decode_plan('` + encodedPlan + `')
+use test;
+select * from t
+where b = 10;
+# Time: 2021-04-15T10:00:01.000000+08:00
+# DB: mysql
+# Is_internal: true
+select * from mysql.stats_meta;
+# Time: 2021-04-15T10:00:02.000000+08:00
+# DB: test
+# Is_internal: false
+insert into t values (1);
+# Time: 2021-04-15T10:00:03.000000+08:00
+# DB: test
+# Is_internal: false
+# Digest: d1
+select * from t where b = 20;
+# Time: 2021-04-15T10:00:04.000000+08:00
+# Is_internal: false
+use other;
+select * from t where a = ? [arguments: 5];
+`
+	qs, err := parseSlowLog(strings.NewReader(slowLog), "")
+	c.Assert(err, IsNil)
+	c.Assert(qs, HasLen, 3)
+	c.Assert(qs[0].Schema, Equals, "test")
+	c.Assert(qs[0].SQL, Equals, "select * from t\nwhere b = 10")
+	c.Assert(qs[0].Digest, Equals, "d1")
+	c.Assert(qs[0].PlanDigest, Equals, "p1")
+	c.Assert(qs[0].PlanFromBinding, IsTrue)
+	c.Assert(qs[2].PlanFromBinding, IsFalse)
+	c.Assert(strings.Contains(qs[0].Plan, "TableFullScan_"), IsTrue)
+	c.Assert(strings.Contains(qs[0].Plan, "table:t, keep order:false"), IsTrue)
+	p, rows, err := parseRecordedPlan(plan.V4, qs[0])
+	c.Assert(err, IsNil)
+	c.Assert(rows, HasLen, 2)
+	c.Assert(p.Root.Type(), Equals, plan.OpTypeTableReader)
+	c.Assert(qs[1].Plan, Equals, "")
+	c.Assert(qs[2].Schema, Equals, "other")
+	c.Assert(qs[2].SQL, Equals, "select * from t where a = ?")
+	c.Assert(qs[2].Params, DeepEquals, []QueryParam{{Type: paramTypeInt, Value: "5"}})
+	c.Assert(qs[2].Digest, Not(Equals), "")
+
+	deduped := dedupQueriesByDigest(qs)
+	c.Assert(deduped, HasLen, 2)
+	c.Assert(deduped[0].SQL, Equals, "select * from t where b = 20")
+
+	qs, err = parseSlowLog(strings.NewReader(slowLog), "OTHER")
+	c.Assert(err, IsNil)
+	c.Assert(qs, HasLen, 1)
+
+	// values with spaces are read as one field
+	e := &slowLogEntry{fields: make(map[string]string)}
+	e.addFields("Prev_stmt: select a: 1 from t")
+	c.Assert(e.fields, DeepEquals, map[string]string{"Prev_stmt": "select a: 1 from t"})
+
+	// undecodable plans and binary plans are reported instead of dropped
+	_, err = parseSlowLog(strings.NewReader(`# Time: 2021-04-15T10:00:05.000000+08:00
+# DB: test
+# Plan: tidb_decode_plan('not a plan')
+select * from t;
+`), "")
+	c.Assert(err, ErrorMatches, "decode the plan of the slow query at 2021-04-15T10:00:05.000000\\+08:00 error: .*")
+	_, err = parseSlowLog(strings.NewReader(`# Time: 2021-04-15T10:00:06.000000+08:00
+# DB: test
+# Binary_plan: tidb_decode_binary_plan('AAAA')
+select * from t;
+`), "")
+	c.Assert(err, ErrorMatches, ".*only has a binary plan, which is not supported.*")
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"database/sql"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -151,12 +152,12 @@ func (db *tidbHandler) stop() {
 	}
 }
 
-func startAndConnectDB(opt tidbAccessOptions, defaultDB string) (*tidbHandler, error) {
+func startAndConnectDB(opt tidbAccessOptions, defaultDB string, out io.Writer) (*tidbHandler, error) {
 	if opt.version == "" {
 		return nil, fmt.Errorf("no TiDB version")
 	}
 	p, port, status := instance.StartTiDB(opt.version, opt.IntPort(), opt.IntStatusPort())
-	fmt.Printf("[PCC]: start TiDB ver=%v, port=%v, statusPort=%v \n", opt.version, port, status)
+	fmt.Fprintf(out, "[PCC]: start TiDB ver=%v, port=%v, statusPort=%v \n", opt.version, port, status)
 
 	opt.port = fmt.Sprintf("%v", port)
 	opt.statusPort = fmt.Sprintf("%v", status)
@@ -171,12 +172,12 @@ func startAndConnectDB(opt tidbAccessOptions, defaultDB string) (*tidbHandler, e
 	return db, nil
 }
 
-func startDB(opt tidbAccessOptions) (*tidbHandler, error) {
+func startDB(opt tidbAccessOptions, out io.Writer) (*tidbHandler, error) {
 	if opt.version == "" {
 		return nil, fmt.Errorf("no TiDB version")
 	}
 	p, port, status := instance.StartTiDB(opt.version, opt.IntPort(), opt.IntStatusPort())
-	fmt.Printf("[PCC]: start TiDB ver=%v, port=%v, statusPort=%v \n", opt.version, port, status)
+	fmt.Fprintf(out, "[PCC]: start TiDB ver=%v, port=%v, statusPort=%v \n", opt.version, port, status)
 
 	opt.port = fmt.Sprintf("%v", port)
 	opt.statusPort = fmt.Sprintf("%v", status)
@@ -188,34 +189,48 @@ func startDB(opt tidbAccessOptions) (*tidbHandler, error) {
 	return db, nil
 }
 
-func connectDB(opt tidbAccessOptions, defaultDB string) (*tidbHandler, error) {
+// dataSourceName returns the DSN to connect to the TiDB, the default database is mysql if defaultDB is empty.
+func dataSourceName(opt tidbAccessOptions, defaultDB string) string {
 	defaultDB = strings.TrimSpace(strings.ToLower(defaultDB))
 	if defaultDB == "" {
 		defaultDB = "mysql"
 	}
-	dns := fmt.Sprintf("%s:%s@tcp(%s:%s)/%v?allowAllFiles=true", opt.user, opt.password, opt.addr, opt.port, defaultDB)
 	if opt.password == "" {
-		dns = fmt.Sprintf("%s@tcp(%s:%s)/%v?allowAllFiles=true", opt.user, opt.addr, opt.port, defaultDB)
+		return fmt.Sprintf("%s@tcp(%s:%s)/%v?allowAllFiles=true", opt.user, opt.addr, opt.port, defaultDB)
 	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%v?allowAllFiles=true", opt.user, opt.password, opt.addr, opt.port, defaultDB)
+}
+
+func connectDB(opt tidbAccessOptions, defaultDB string) (*tidbHandler, error) {
+	dns := dataSourceName(opt, defaultDB)
 	db, err := sql.Open("mysql", dns)
 	if err != nil {
 		return nil, fmt.Errorf("connect to database dns:%v, error: %v", dns, err)
 	}
+	if err := pingDB(db, dns); err != nil {
+		return nil, err
+	}
+	return &tidbHandler{opt, db, nil}, nil
+}
+
+// pingDB retries until the TiDB is ready to serve.
+func pingDB(db *sql.DB, dns string) error {
+	var err error
 	for i := 0; i < 10; i++ {
 		if err = db.Ping(); err != nil {
-			fmt.Printf("ping DB %v error: %v, retrying", dns, err)
+			fmt.Fprintf(os.Stderr, "ping DB %v error: %v, retrying\n", dns, err)
 			time.Sleep(1 * time.Second)
 		} else {
 			break
 		}
 	}
 	if err != nil {
-		return nil, fmt.Errorf("ping DB %v error: %v, retrying", dns, err)
+		return fmt.Errorf("ping DB %v error: %v, retrying", dns, err)
 	}
 	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("ping DB %v error: %v", dns, err)
+		return fmt.Errorf("ping DB %v error: %v", dns, err)
 	}
-	return &tidbHandler{opt, db, nil}, nil
+	return nil
 }
 
 func tmpPathDir() string {
@@ -288,4 +303,16 @@ func parseDBTables(dir string) (map[string][]string, map[string][]string, error)
 type Query struct {
 	Schema string `json:"schema"`
 	SQL    string `json:"sql"`
+	Digest string `json:"digest,omitempty"`
+	// Plan is the plan text recorded by TiDB when the query ran.
+	Plan string `json:"plan,omitempty"`
+	// PlanFromBinding is whether the recorded plan is produced under a binding.
+	PlanFromBinding bool `json:"planFromBinding,omitempty"`
+	// Params are arguments of the query if it's a prepared statement, whose SQL keeps the placeholders.
+	Params []QueryParam `json:"params,omitempty"`
+	// PlanDigest, ExecCount and latencies are execution statistics recorded by TiDB.
+	PlanDigest string        `json:"planDigest,omitempty"`
+	ExecCount  int64         `json:"execCount,omitempty"`
+	AvgLatency time.Duration `json:"avgLatency,omitempty"`
+	MaxLatency time.Duration `json:"maxLatency,omitempty"`
 }
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// runWorkers calls fn(worker, i) for all i in [0, n) with the given number of workers,
+// fn is called by at most one goroutine at the same time for each worker.
+func runWorkers(concurrency, n int, fn func(worker, i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+	taskCh := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range taskCh {
+				fn(worker, i)
+			}
+		}(w)
+	}
+	for i := 0; i < n; i++ {
+		taskCh <- i
+	}
+	close(taskCh)
+	wg.Wait()
+}
+
+// progressInterval is the min interval between two progress messages.
+var progressInterval = 10 * time.Second
+
+// progressReporter calls report for finished tasks in the order of their indexes as soon as
+// all previous tasks are finished, and prints the progress into out periodically.
+type progressReporter struct {
+	mu        sync.Mutex
+	name      string
+	out       io.Writer
+	done      []bool
+	next      int
+	report    func(i int)
+	lastPrint time.Time
+}
+
+func newProgressReporter(name string, n int, out io.Writer, report func(i int)) *progressReporter {
+	return &progressReporter{name: name, out: out, done: make([]bool, n), report: report, lastPrint: time.Now()}
+}
+
+// finish marks the i-th task as finished.
+func (p *progressReporter) finish(i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[i] = true
+	for p.next < len(p.done) && p.done[p.next] {
+		if p.report != nil {
+			p.report(p.next)
+		}
+		p.next++
+	}
+	if p.next == len(p.done) || time.Since(p.lastPrint) >= progressInterval {
+		p.lastPrint = time.Now()
+		fmt.Fprintf(p.out, "[PCC] %v: %v/%v queries processed\n", p.name, p.next, len(p.done))
+	}
+}
+
+// sessionHandler is a TiDB handler owned by a single worker, it uses a dedicated
+// connection so that session states like the current database are not shared.
+type sessionHandler struct {
+	*tidbHandler
+	schema    string
+	connector *sessionConnector
+}
+
+// sessionConnector connects to the current database of the session, so the connection
+// reconnected by database/sql after it's dropped is still in the cached database.
+type sessionConnector struct {
+	cfg *mysql.Config
+
+	mu     sync.Mutex
+	schema string
+}
+
+func (c *sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := mysql.NewConnector(c.config())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// config returns the config to connect with, whose database is the current one of the session.
+func (c *sessionConnector) config() *mysql.Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg := c.cfg.Clone()
+	if c.schema != "" {
+		cfg.DBName = c.schema
+	}
+	return cfg
+}
+
+func (c *sessionConnector) Driver() driver.Driver {
+	return mysql.MySQLDriver{}
+}
+
+func (c *sessionConnector) setSchema(schema string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schema = schema
+}
+
+// newSessions opens n dedicated connections to the TiDB, whose current database is defaultDB at first.
+func newSessions(db *tidbHandler, defaultDB string, n int) ([]*sessionHandler, error) {
+	dns := dataSourceName(db.opt, defaultDB)
+	cfg, err := mysql.ParseDSN(dns)
+	if err != nil {
+		return nil, fmt.Errorf("parse dns:%v error: %v", dns, err)
+	}
+	sessions := make([]*sessionHandler, 0, n)
+	for i := 0; i < n; i++ {
+		connector := &sessionConnector{cfg: cfg}
+		sqlDB := sql.OpenDB(connector)
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		if err := pingDB(sqlDB, dns); err != nil {
+			sqlDB.Close()
+			closeSessions(sessions)
+			return nil, err
+		}
+		h := &tidbHandler{opt: db.opt, db: sqlDB}
+		sessions = append(sessions, &sessionHandler{tidbHandler: h, connector: connector})
+	}
+	return sessions, nil
+}
+
+func closeSessions(sessions []*sessionHandler) {
+	for _, s := range sessions {
+		s.db.Close()
+	}
+}
+
+// use switches the current database of the session if it's different, the cached database
+// is still valid after reconnecting since the connector connects to it.
+func (s *sessionHandler) use(schema string) error {
+	if schema == "" || strings.EqualFold(schema, s.schema) {
+		return nil
+	}
+	if _, err := s.db.Exec(fmt.Sprintf("use `%v`", schema)); err != nil {
+		return err
+	}
+	s.schema = schema
+	s.connector.setSchema(schema)
+	return nil
+}
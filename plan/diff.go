@@ -0,0 +1,331 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffKind is the category of a difference between two plans.
+type DiffKind string
+
+const (
+	DiffSQL            DiffKind = "sql"
+	DiffOperatorType   DiffKind = "operator_type"
+	DiffJoinAlgorithm  DiffKind = "join_algorithm"
+	DiffJoinType       DiffKind = "join_type"
+	DiffJoinOrder      DiffKind = "join_order"
+	DiffAccessPath     DiffKind = "access_path"
+	DiffIndex          DiffKind = "index"
+	DiffTask           DiffKind = "task"
+	DiffSubtreeAdded   DiffKind = "subtree_added"
+	DiffSubtreeRemoved DiffKind = "subtree_removed"
+)
+
+// DiffEntry is a difference between two plans.
+type DiffEntry struct {
+	Kind DiffKind `json:"kind"`
+	// Path is the operator IDs from the root to the differing node, IDs of the
+	// first plan are used except for added subtrees.
+	Path  []string `json:"path"`
+	OldID string   `json:"oldID,omitempty"`
+	NewID string   `json:"newID,omitempty"`
+	// OldRow and NewRow locate the differing operators in their plans, they are valid only if the IDs are set.
+	OldRow   int      `json:"oldRow,omitempty"`
+	NewRow   int      `json:"newRow,omitempty"`
+	Reason   string   `json:"reason"`
+	Severity Severity `json:"severity"`
+
+	Old Operator `json:"-"`
+	New Operator `json:"-"`
+}
+
+func (e DiffEntry) String() string {
+	return fmt.Sprintf("[%v][%v] %v: %v", e.Severity, e.Kind, strings.Join(e.Path, " > "), e.Reason)
+}
+
+// PlanDiff contains all differences between two plans in pre-order.
+type PlanDiff struct {
+	Entries []DiffEntry `json:"entries"`
+	// EstRowsDrifts doesn't affect whether two plans are the same, see WithEstRowsDrift.
+	EstRowsDrifts []EstRowsDrift `json:"estRowsDrifts,omitempty"`
+}
+
+func (d PlanDiff) Same() bool {
+	return len(d.Entries) == 0
+}
+
+// Reason returns the reason of the first difference.
+func (d PlanDiff) Reason() string {
+	if len(d.Entries) == 0 {
+		return ""
+	}
+	return d.Entries[0].Reason
+}
+
+func (d *PlanDiff) add(kind DiffKind, path []string, op1, op2 Operator, reason string) {
+	e := DiffEntry{Kind: kind, Path: append([]string(nil), path...), Reason: reason, Old: op1, New: op2}
+	if op1 != nil {
+		e.OldID, e.OldRow = op1.ID(), op1.Row()
+	}
+	if op2 != nil {
+		e.NewID, e.NewRow = op2.ID(), op2.Row()
+	}
+	d.Entries = append(d.Entries, e)
+}
+
+func Compare(p1, p2 Plan, opts ...CompareOption) (reason string, same bool) {
+	d := CompareDetailed(p1, p2, opts...)
+	return d.Reason(), d.Same()
+}
+
+// CompareDetailed compares two plans and returns all differences instead of the first one.
+func CompareDetailed(p1, p2 Plan, opts ...CompareOption) PlanDiff {
+	var d PlanDiff
+	c := &comparer{opts: newCompareOptions(opts)}
+	if p1.SQL != p2.SQL {
+		d.add(DiffSQL, nil, nil, nil, "differentiate SQLs")
+	} else {
+		c.tblAlias = fillInAlias(p1.SQL)
+		c.compare(p1.Root, p2.Root, nil, &d)
+	}
+	for i := range d.Entries {
+		d.Entries[i].Severity = c.opts.classify(d.Entries[i])
+	}
+	return d
+}
+
+type comparer struct {
+	opts     *compareOptions
+	tblAlias map[string]string
+	// inJoinTree is true when comparing operators under a join, whose join order has been checked
+	inJoinTree bool
+	// reordered is true when comparing operators in a join tree whose join order is different,
+	// children of its joins are matched by tables and scans of different tables are not reported.
+	reordered bool
+}
+
+func (c *comparer) compare(op1, op2 Operator, parentPath []string, d *PlanDiff) {
+	op1, op2 = c.opts.normalize(op1), c.opts.normalize(op2)
+	if c.opts.sameSubtree(op1, op2, c.tblAlias) {
+		return
+	}
+
+	path := append(parentPath[:len(parentPath):len(parentPath)], op1.ID())
+	isJoin := OpTypeIsJoin(op1.Type()) && OpTypeIsJoin(op2.Type())
+	if isJoin && !c.inJoinTree {
+		reordered := c.reordered
+		c.reordered = c.compareJoinOrder(op1, op2, path, d)
+		defer func() { c.reordered = reordered }()
+	}
+	// a join tree ends at non-join operators, joins under them are roots of nested join trees
+	inJoinTree := c.inJoinTree
+	c.inJoinTree = isJoin
+	defer func() { c.inJoinTree = inJoinTree }()
+	if !c.opts.sameOperator(op1, op2) {
+		kind := DiffOperatorType
+		if OpTypeIsJoin(op1.Type()) && OpTypeIsJoin(op2.Type()) {
+			kind = DiffJoinAlgorithm
+		} else if isAccessOp(op1.Type()) && isAccessOp(op2.Type()) {
+			kind = DiffAccessPath
+		}
+		d.add(kind, path, op1, op2, fmt.Sprintf("different operators %v and %v", op1.ID(), op2.ID()))
+		return
+	}
+	if op1.Task() != op2.Task() {
+		d.add(DiffTask, path, op1, op2, fmt.Sprintf("different tasks %v:%v and %v:%v", op1.ID(), op1.Task(), op2.ID(), op2.Task()))
+		return
+	}
+	c.checkEstRowsDrift(op1, op2, path, d)
+
+	if jt1, jt2 := JoinTypeOf(op1), JoinTypeOf(op2); jt1 != jt2 {
+		d.add(DiffJoinType, path, op1, op2, fmt.Sprintf("different join types %v:%v and %v:%v", op1.ID(), jt1, op2.ID(), jt2))
+	}
+
+	switch op1.Type() {
+	case OpTypeTableScan:
+		if op2.Type() != OpTypeTableScan {
+			break
+		}
+		t1, t2 := op1.(TableScanOp), op2.(TableScanOp)
+		if !sameTable(t1.Table, t2.Table, c.tblAlias) && !c.reordered {
+			d.add(DiffAccessPath, path, op1, op2, fmt.Sprintf("different table scan %v:%v, %v:%v", t1.ID(), t1.Table, t2.ID(), t2.Table))
+		}
+	case OpTypeIndexScan:
+		if op2.Type() != OpTypeIndexScan {
+			break
+		}
+		t1, t2 := op1.(IndexScanOp), op2.(IndexScanOp)
+		reason := fmt.Sprintf("different index scan %v:%v:%v, %v:%v:%v", t1.ID(), t1.Table, t1.Index, t2.ID(), t2.Table, t2.Index)
+		if !sameTable(t1.Table, t2.Table, c.tblAlias) {
+			if !c.reordered {
+				d.add(DiffAccessPath, path, op1, op2, reason)
+			}
+		} else if t1.Index != t2.Index {
+			d.add(DiffIndex, path, op1, op2, reason)
+		}
+	}
+
+	c1, c2 := op1.Children(), op2.Children()
+	if len(c1) != len(c2) {
+		reason := fmt.Sprintf("%v and %v have different children lengths", op1.ID(), op2.ID())
+		for i := len(c2); i < len(c1); i++ {
+			d.add(DiffSubtreeRemoved, append(path, c1[i].ID()), c1[i], nil, reason)
+		}
+		for i := len(c1); i < len(c2); i++ {
+			d.add(DiffSubtreeAdded, append(path, c2[i].ID()), nil, c2[i], reason)
+		}
+	}
+	if len(c1) == 2 && len(c2) == 2 && c.opts.ignoreChildOrder(op1, op2) {
+		var inOrder, swapped PlanDiff
+		c.compare(c1[0], c2[0], path, &inOrder)
+		c.compare(c1[1], c2[1], path, &inOrder)
+		if !inOrder.Same() {
+			c.compare(c1[0], c2[1], path, &swapped)
+			c.compare(c1[1], c2[0], path, &swapped)
+			if swapped.Same() {
+				d.EstRowsDrifts = append(d.EstRowsDrifts, swapped.EstRowsDrifts...)
+				return
+			}
+		}
+		d.Entries = append(d.Entries, inOrder.Entries...)
+		d.EstRowsDrifts = append(d.EstRowsDrifts, inOrder.EstRowsDrifts...)
+		return
+	}
+	if isJoin && c.reordered && len(c1) == 2 && len(c2) == 2 && c.childrenSwapped(c1, c2) {
+		// the difference is already reported as a different join order
+		c1 = []Operator{c1[1], c1[0]}
+	}
+	for i := 0; i < len(c1) && i < len(c2); i++ {
+		c.compare(c1[i], c2[i], path, d)
+	}
+}
+
+// childrenSwapped returns whether the two children of joins read the same tables in the swapped order.
+func (c *comparer) childrenSwapped(c1, c2 []Operator) bool {
+	sameTables := func(op1, op2 Operator) bool {
+		t1, t2 := c.subtreeTables(op1), c.subtreeTables(op2)
+		return len(t1) == len(t2) && sameTableSet(t1, t2, c.tblAlias)
+	}
+	if sameTables(c1[0], c2[0]) && sameTables(c1[1], c2[1]) {
+		return false
+	}
+	return sameTables(c1[0], c2[1]) && sameTables(c1[1], c2[0])
+}
+
+func (c *comparer) subtreeTables(op Operator) []string {
+	op = c.opts.normalize(op)
+	if OpTypeIsJoin(op.Type()) {
+		return c.joinTables(op)
+	}
+	return c.leafTables(op)
+}
+
+// compareJoinOrder reports a difference if the two join trees join the same tables in different orders,
+// it returns whether the difference is reported.
+func (c *comparer) compareJoinOrder(op1, op2 Operator, path []string, d *PlanDiff) bool {
+	if c.opts.ignoreChildOrder(op1, op2) {
+		return false
+	}
+	t1, t2 := c.joinTables(op1), c.joinTables(op2)
+	if len(t1) != len(t2) {
+		return false
+	}
+	sameOrder := true
+	for i := range t1 {
+		if !sameTable(t1[i], t2[i], c.tblAlias) {
+			sameOrder = false
+			break
+		}
+	}
+	if sameOrder || !sameTableSet(t1, t2, c.tblAlias) {
+		// different tables are reported as different access paths
+		return false
+	}
+	d.add(DiffJoinOrder, path, op1, op2, fmt.Sprintf("different join orders [%v] and [%v]", strings.Join(t1, ", "), strings.Join(t2, ", ")))
+	return true
+}
+
+// joinTables returns the base tables in the join tree from left to right, a nested join tree
+// is represented by its first table since its order is checked at its own root.
+func (c *comparer) joinTables(op Operator) []string {
+	var tables []string
+	for _, child := range op.Children() {
+		child = c.opts.normalize(child)
+		if OpTypeIsJoin(child.Type()) {
+			tables = append(tables, c.joinTables(child)...)
+		} else {
+			tables = append(tables, c.leafTables(child)...)
+		}
+	}
+	return tables
+}
+
+// leafTables returns the base tables under a leaf of a join tree.
+func (c *comparer) leafTables(op Operator) []string {
+	if OpTypeIsDataSource(op.Type()) || op.Type() == OpTypeTableScan || op.Type() == OpTypeIndexScan || hasJoin(op) {
+		if tbl := firstTable(op); tbl != "" {
+			return []string{tbl}
+		}
+		return nil
+	}
+	var tables []string
+	for _, child := range op.Children() {
+		tables = append(tables, c.leafTables(c.opts.normalize(child))...)
+	}
+	return tables
+}
+
+func hasJoin(op Operator) bool {
+	if OpTypeIsJoin(op.Type()) {
+		return true
+	}
+	for _, child := range op.Children() {
+		if hasJoin(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstTable(op Operator) string {
+	switch v := op.(type) {
+	case TableScanOp:
+		return v.Table
+	case IndexScanOp:
+		return v.Table
+	case PointGetOp:
+		return v.Table
+	}
+	for _, child := range op.Children() {
+		if tbl := firstTable(child); tbl != "" {
+			return tbl
+		}
+	}
+	return ""
+}
+
+func sameTableSet(t1, t2 []string, tblAlias map[string]string) bool {
+	used := make([]bool, len(t2))
+	for _, a := range t1 {
+		found := false
+		for j, b := range t2 {
+			if !used[j] && sameTable(a, b, tblAlias) {
+				used[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// isAccessOp returns whether the operator reads data from storage.
+func isAccessOp(opType OpType) bool {
+	switch opType {
+	case OpTypeTableScan, OpTypeIndexScan:
+		return true
+	}
+	return OpTypeIsDataSource(opType)
+}
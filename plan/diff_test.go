@@ -0,0 +1,203 @@
+package plan
+
+import (
+	. "github.com/pingcap/check"
+)
+
+func (s *parseTestSuite) TestCompareDetailed(c *C) {
+	sql := `explain select * from t1, t2 where t1.a=t2.a and t2.b=1`
+	p1 := `
++--------------------------------+---------+-----------+----------------------+--------------------------------------------+
+| id                             | estRows | task      | access object        | operator info                              |
++--------------------------------+---------+-----------+----------------------+--------------------------------------------+
+| HashJoin_10                    | 12.49   | root      |                      | inner join, equal:[eq(test.t1.a, test.t2.a)] |
+| ├─IndexLookUp_20(Build)        | 9.99    | root      |                      |                                            |
+| │ ├─IndexRangeScan_18(Build)   | 10.00   | cop[tikv] | table:t2, index:b(b) | range:[1,1], keep order:false              |
+| │ └─Selection_19(Probe)        | 9.99    | cop[tikv] |                      | not(isnull(test.t2.a))                     |
+| │   └─TableRowIDScan_17        | 10.00   | cop[tikv] | table:t2             | keep order:false                           |
+| └─TableReader_23(Probe)        | 9990.00 | root      |                      | data:Selection_22                          |
+|   └─Selection_22               | 9990.00 | cop[tikv] |                      | not(isnull(test.t1.a))                     |
+|     └─TableFullScan_21         | 10000.00| cop[tikv] | table:t1             | keep order:false                           |
++--------------------------------+---------+-----------+----------------------+--------------------------------------------+
+`
+	p2 := `
++--------------------------------+---------+--------------+------------------------+--------------------------------------------+
+| id                             | estRows | task         | access object          | operator info                              |
++--------------------------------+---------+--------------+------------------------+--------------------------------------------+
+| MergeJoin_10                   | 12.49   | root         |                        | inner join, left key:test.t1.a             |
+| ├─IndexLookUp_20(Build)        | 9.99    | root         |                        |                                            |
+| │ ├─IndexRangeScan_18(Build)   | 10.00   | cop[tikv]    | table:t2, index:ab(a, b) | range:[1,1], keep order:false            |
+| │ └─TableRowIDScan_17(Probe)   | 10.00   | cop[tikv]    | table:t2               | keep order:false                           |
+| └─TableReader_23(Probe)        | 9990.00 | root         |                        | data:Selection_22                          |
+|   └─Selection_22               | 9990.00 | mpp[tiflash] |                        | not(isnull(test.t1.a))                     |
+|     └─TableFullScan_21         | 10000.00| mpp[tiflash] | table:t1               | keep order:false                           |
++--------------------------------+---------+--------------+------------------------+--------------------------------------------+
+`
+	plan1, err := ParseText(sql, p1, V4)
+	c.Assert(err, IsNil)
+	plan2, err := ParseText(sql, p2, V4)
+	c.Assert(err, IsNil)
+
+	d := CompareDetailed(plan1, plan2)
+	c.Assert(d.Same(), IsFalse)
+	c.Assert(len(d.Entries), Equals, 1)
+	c.Assert(d.Entries[0].Kind, Equals, DiffJoinAlgorithm)
+	c.Assert(d.Entries[0].Path, DeepEquals, []string{"HashJoin_10"})
+
+	// compare the children of the join only
+	plan1.Root, plan2.Root = plan1.Root.Children()[0], plan2.Root.Children()[0]
+	d = CompareDetailed(plan1, plan2)
+	c.Assert(len(d.Entries), Equals, 2)
+	c.Assert(d.Entries[0].Kind, Equals, DiffIndex)
+	c.Assert(d.Entries[0].Path, DeepEquals, []string{"IndexLookUp_20(Build)", "IndexRangeScan_18(Build)"})
+	c.Assert(d.Entries[1].Kind, Equals, DiffOperatorType)
+	c.Assert(d.Entries[1].OldID, Equals, "Selection_19(Probe)")
+	c.Assert(d.Entries[1].NewID, Equals, "TableRowIDScan_17(Probe)")
+
+	plan1, err = ParseText(sql, p1, V4)
+	c.Assert(err, IsNil)
+	plan2, err = ParseText(sql, p2, V4)
+	c.Assert(err, IsNil)
+	plan1.Root, plan2.Root = plan1.Root.Children()[1], plan2.Root.Children()[1]
+	d = CompareDetailed(plan1, plan2)
+	c.Assert(len(d.Entries), Equals, 1)
+	c.Assert(d.Entries[0].Kind, Equals, DiffTask)
+	c.Assert(d.Entries[0].Path, DeepEquals, []string{"TableReader_23(Probe)", "Selection_22"})
+	reason, same := Compare(plan1, plan2)
+	c.Assert(same, IsFalse)
+	c.Assert(reason, Equals, d.Reason())
+}
+
+func (s *parseTestSuite) TestCompareDetailedSubtree(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	rows1 := [][]string{
+		{"Union_8", "20.00", "root", "", ""},
+		{"├─TableReader_10", "10.00", "root", "", ""},
+		{"│ └─TableFullScan_9", "10.00", "cop[tikv]", "table:t1", ""},
+		{"└─TableReader_12", "10.00", "root", "", ""},
+		{"  └─TableFullScan_11", "10.00", "cop[tikv]", "table:t2", ""},
+	}
+	rows2 := rows1[:3]
+	plan1, err := Parse(V5, "", header, rows1)
+	c.Assert(err, IsNil)
+	plan2, err := Parse(V5, "", header, rows2)
+	c.Assert(err, IsNil)
+
+	d := CompareDetailed(plan1, plan2)
+	c.Assert(len(d.Entries), Equals, 1)
+	c.Assert(d.Entries[0].Kind, Equals, DiffSubtreeRemoved)
+	c.Assert(d.Entries[0].Path, DeepEquals, []string{"Union_8", "TableReader_12"})
+
+	d = CompareDetailed(plan2, plan1)
+	c.Assert(len(d.Entries), Equals, 1)
+	c.Assert(d.Entries[0].Kind, Equals, DiffSubtreeAdded)
+	c.Assert(d.Entries[0].NewID, Equals, "TableReader_12")
+}
+
+func (s *parseTestSuite) TestCompareJoinTypeAndOrder(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	join := func(joinInfo, t1, t2, t3 string) Plan {
+		p, err := Parse(V5, "", header, [][]string{
+			{"HashJoin_8", "10.00", "root", "", joinInfo},
+			{"├─HashJoin_9(Build)", "10.00", "root", "", "inner join"},
+			{"│ ├─TableReader_10(Build)", "10.00", "root", "", ""},
+			{"│ │ └─TableFullScan_11", "10.00", "cop[tikv]", "table:" + t1, ""},
+			{"│ └─IndexLookUp_12(Probe)", "10.00", "root", "", ""},
+			{"│   ├─IndexRangeScan_13(Build)", "10.00", "cop[tikv]", "table:" + t2 + ", index:a(a)", ""},
+			{"│   └─TableRowIDScan_14(Probe)", "10.00", "cop[tikv]", "table:" + t2, ""},
+			{"└─TableReader_15(Probe)", "10.00", "root", "", ""},
+			{"  └─TableFullScan_16", "10.00", "cop[tikv]", "table:" + t3, ""},
+		})
+		c.Assert(err, IsNil)
+		return p
+	}
+
+	p := join("left outer join, equal:[eq(t1.a, t3.a)]", "t1", "t2", "t3")
+	c.Assert(p.Root.(HashJoinOp).JoinType, Equals, JoinTypeLeftOuter)
+	c.Assert(p.Root.Children()[0].(HashJoinOp).JoinType, Equals, JoinTypeInner)
+	c.Assert(CompareDetailed(p, p).Same(), IsTrue)
+
+	d := CompareDetailed(p, join("anti semi join, equal:[eq(t1.a, t3.a)]", "t1", "t2", "t3"))
+	c.Assert(len(d.Entries), Equals, 1)
+	c.Assert(d.Entries[0].Kind, Equals, DiffJoinType)
+	c.Assert(d.Entries[0].Reason, Equals, "different join types HashJoin_8:left outer join and HashJoin_8:anti semi join")
+
+	d = CompareDetailed(p, join("left outer join", "t3", "t2", "t1"))
+	c.Assert(d.Entries[0].Kind, Equals, DiffJoinOrder)
+	c.Assert(d.Entries[0].Path, DeepEquals, []string{"HashJoin_8"})
+	c.Assert(d.Entries[0].Reason, Equals, "different join orders [t1, t2, t3] and [t3, t2, t1]")
+	// scans of different tables in the reordered join tree are not reported again
+	c.Assert(d.Entries, HasLen, 1)
+
+	// different tables are not reported as a different join order
+	d = CompareDetailed(p, join("left outer join", "t1", "t2", "t4"))
+	c.Assert(len(d.Entries), Equals, 1)
+	c.Assert(d.Entries[0].Kind, Equals, DiffAccessPath)
+
+	// children of a join whose build and probe sides are swapped are matched by tables
+	swapped := func(build, probe string) Plan {
+		p, err := Parse(V4, "", header, [][]string{
+			{"HashJoin_8", "10.00", "root", "", "inner join"},
+			{"├─TableReader_10(Build)", "10.00", "root", "", ""},
+			{"│ └─TableFullScan_9", "10.00", "cop[tikv]", "table:" + build, ""},
+			{"└─IndexReader_12(Probe)", "10.00", "root", "", ""},
+			{"  └─IndexFullScan_11", "10.00", "cop[tikv]", "table:" + probe + ", index:a(a)", ""},
+		})
+		c.Assert(err, IsNil)
+		return p
+	}
+	d = CompareDetailed(swapped("t1", "t2"), swapped("t2", "t1"))
+	c.Assert(d.Entries, HasLen, 3)
+	c.Assert(d.Entries[0].Kind, Equals, DiffJoinOrder)
+	// access paths of the same table are compared, t2 is read by the index before and by the table after
+	c.Assert(d.Entries[1].Kind, Equals, DiffAccessPath)
+	c.Assert(d.Entries[1].Path, DeepEquals, []string{"HashJoin_8", "IndexReader_12(Probe)"})
+	c.Assert(d.Entries[1].NewID, Equals, "TableReader_10(Build)")
+	c.Assert(d.Entries[2].Kind, Equals, DiffAccessPath)
+	c.Assert(d.Entries[2].NewID, Equals, "IndexReader_12(Probe)")
+
+	build := func(buildTable, probeTable string) Plan {
+		p, err := Parse(V4, "", header, [][]string{
+			{"HashJoin_8", "10.00", "root", "", "inner join"},
+			{"├─TableReader_10(Build)", "10.00", "root", "", ""},
+			{"│ └─TableFullScan_9", "10.00", "cop[tikv]", "table:" + buildTable, ""},
+			{"└─TableReader_12(Probe)", "10.00", "root", "", ""},
+			{"  └─TableFullScan_11", "10.00", "cop[tikv]", "table:" + probeTable, ""},
+		})
+		c.Assert(err, IsNil)
+		return p
+	}
+	d = CompareDetailed(build("t1", "t2"), build("t2", "t1"))
+	c.Assert(d.Entries, HasLen, 1)
+	c.Assert(d.Entries[0].Kind, Equals, DiffJoinOrder)
+
+	// join trees nested under non-join operators are checked at their own roots
+	nested := func(t1, t2 string) Plan {
+		p, err := Parse(V5, "", header, [][]string{
+			{"Projection_5", "10.00", "root", "", ""},
+			{"└─HashJoin_6", "10.00", "root", "", "inner join"},
+			{"  ├─TableReader_7(Build)", "10.00", "root", "", ""},
+			{"  │ └─TableFullScan_8", "10.00", "cop[tikv]", "table:t0", ""},
+			{"  └─Selection_9(Probe)", "10.00", "root", "", "gt(test.t1.a, 1)"},
+			{"    └─HashJoin_10", "10.00", "root", "", "inner join"},
+			{"      ├─TableReader_11(Build)", "10.00", "root", "", ""},
+			{"      │ └─TableFullScan_12", "10.00", "cop[tikv]", "table:" + t1, ""},
+			{"      └─TableReader_13(Probe)", "10.00", "root", "", ""},
+			{"        └─TableFullScan_14", "10.00", "cop[tikv]", "table:" + t2, ""},
+		})
+		c.Assert(err, IsNil)
+		return p
+	}
+	d = CompareDetailed(nested("t1", "t2"), nested("t2", "t1"))
+	c.Assert(d.Entries[0].Kind, Equals, DiffJoinOrder)
+	c.Assert(d.Entries[0].Path, DeepEquals, []string{"HashJoin_6", "Selection_9(Probe)", "HashJoin_10"})
+	c.Assert(d.Entries[0].Reason, Equals, "different join orders [t1, t2] and [t2, t1]")
+
+	cmp := &comparer{opts: newCompareOptions(nil)}
+	c.Assert(cmp.joinTables(join("inner join", "t1", "t2", "t3").Root), DeepEquals, []string{"t1", "t2", "t3"})
+	c.Assert(cmp.joinTables(nested("t1", "t2").Root.Children()[0]), DeepEquals, []string{"t0", "t1"})
+
+	c.Assert(parseJoinType("anti left outer semi join, other cond:eq(t1.a, t2.a)"), Equals, JoinTypeAntiLeftOuterSemi)
+	c.Assert(parseJoinType("semi join, inner:TableReader_12"), Equals, JoinTypeSemi)
+	c.Assert(parseJoinType("data:Selection_12"), Equals, JoinTypeUnknown)
+}
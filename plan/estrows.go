@@ -0,0 +1,63 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// EstRowsDrift is a change of the estimated rows of an operator existing in both plans.
+type EstRowsDrift struct {
+	Path       []string `json:"path"`
+	OldID      string   `json:"oldID"`
+	NewID      string   `json:"newID"`
+	OldEstRows float64  `json:"oldEstRows"`
+	NewEstRows float64  `json:"newEstRows"`
+	// Ratio is the larger estimation divided by the smaller one, it's always >= 1.
+	Ratio float64 `json:"ratio"`
+}
+
+func (e EstRowsDrift) String() string {
+	return fmt.Sprintf("%v: estimated rows %.2f -> %.2f (%.2fx)", strings.Join(e.Path, " > "), e.OldEstRows, e.NewEstRows, e.Ratio)
+}
+
+// WithEstRowsDrift reports operators whose estimated rows change by more than ratio times,
+// the check is disabled if ratio <= 1.
+func WithEstRowsDrift(ratio float64) CompareOption {
+	return func(opts *compareOptions) {
+		opts.estRowsDriftRatio = ratio
+	}
+}
+
+// MaxEstRowsDrift returns the max ratio of all drifts, or 0 if there is no drift.
+func (d PlanDiff) MaxEstRowsDrift() float64 {
+	maxRatio := 0.0
+	for _, e := range d.EstRowsDrifts {
+		maxRatio = math.Max(maxRatio, e.Ratio)
+	}
+	return maxRatio
+}
+
+// estRowsRatio smooths zero estimations by adding 1 to both sides.
+func estRowsRatio(r1, r2 float64) float64 {
+	r1, r2 = math.Max(r1, 0)+1, math.Max(r2, 0)+1
+	return math.Max(r1, r2) / math.Min(r1, r2)
+}
+
+func (c *comparer) checkEstRowsDrift(op1, op2 Operator, path []string, d *PlanDiff) {
+	if c.opts.estRowsDriftRatio <= 1 {
+		return
+	}
+	ratio := estRowsRatio(op1.EstRow(), op2.EstRow())
+	if ratio < c.opts.estRowsDriftRatio {
+		return
+	}
+	d.EstRowsDrifts = append(d.EstRowsDrifts, EstRowsDrift{
+		Path:       append([]string(nil), path...),
+		OldID:      op1.ID(),
+		NewID:      op2.ID(),
+		OldEstRows: op1.EstRow(),
+		NewEstRows: op2.EstRow(),
+		Ratio:      ratio,
+	})
+}
@@ -0,0 +1,43 @@
+package plan
+
+import (
+	. "github.com/pingcap/check"
+)
+
+func (s *parseTestSuite) TestEstRowsDrift(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	parse := func(readerRows, scanRows string) Plan {
+		p, err := Parse(V5, "", header, [][]string{
+			{"TableReader_7", readerRows, "root", "", ""},
+			{"└─Selection_6", readerRows, "cop[tikv]", "", ""},
+			{"  └─TableFullScan_5", scanRows, "cop[tikv]", "table:t", ""},
+		})
+		c.Assert(err, IsNil)
+		return p
+	}
+	p1 := parse("10.00", "10000.00")
+	p2 := parse("5000.00", "12000.00")
+
+	d := CompareDetailed(p1, p2)
+	c.Assert(d.Same(), IsTrue)
+	c.Assert(d.EstRowsDrifts, HasLen, 0)
+
+	d = CompareDetailed(p1, p2, WithEstRowsDrift(10))
+	c.Assert(d.Same(), IsTrue)
+	c.Assert(d.EstRowsDrifts, HasLen, 2)
+	c.Assert(d.EstRowsDrifts[0].Path, DeepEquals, []string{"TableReader_7"})
+	c.Assert(d.EstRowsDrifts[1].Path, DeepEquals, []string{"TableReader_7", "Selection_6"})
+	c.Assert(d.EstRowsDrifts[0].OldEstRows, Equals, 10.0)
+	c.Assert(d.EstRowsDrifts[0].NewEstRows, Equals, 5000.0)
+	c.Assert(d.MaxEstRowsDrift(), Equals, 5001.0/11)
+	c.Assert(d.EstRowsDrifts[0].String(), Equals, "TableReader_7: estimated rows 10.00 -> 5000.00 (454.64x)")
+
+	// decreasing estimations are reported too
+	d = CompareDetailed(p2, p1, WithEstRowsDrift(1000))
+	c.Assert(d.EstRowsDrifts, HasLen, 0)
+	d = CompareDetailed(p2, p1, WithEstRowsDrift(100))
+	c.Assert(d.EstRowsDrifts, HasLen, 2)
+
+	c.Assert(estRowsRatio(0, 0), Equals, 1.0)
+	c.Assert(estRowsRatio(0, 9), Equals, 10.0)
+}
@@ -0,0 +1,252 @@
+package plan
+
+import (
+	"encoding/json"
+
+	"github.com/pingcap/errors"
+)
+
+// opTypeNames are names of operator types in the JSON encoding of plans.
+var opTypeNames = map[OpType]string{
+	OpTypeUnknown:          "Unknown",
+	OpTypeHashJoin:         "HashJoin",
+	OpTypeIndexJoin:        "IndexJoin",
+	OpTypeMergeJoin:        "MergeJoin",
+	OpTypeHashAgg:          "HashAgg",
+	OpTypeStreamAgg:        "StreamAgg",
+	OpTypeSelection:        "Selection",
+	OpTypeProjection:       "Projection",
+	OpTypeTableReader:      "TableReader",
+	OpTypeTableScan:        "TableScan",
+	OpTypeIndexReader:      "IndexReader",
+	OpTypeIndexScan:        "IndexScan",
+	OpTypeIndexLookup:      "IndexLookup",
+	OpTypePointGet:         "PointGet",
+	OpTypeMaxOneRow:        "MaxOneRow",
+	OpTypeApply:            "Apply",
+	OpTypeLimit:            "Limit",
+	OpTypeSort:             "Sort",
+	OpTypeTopN:             "TopN",
+	OpTypeTableDual:        "TableDual",
+	OpTypeSelectLock:       "SelectLock",
+	OpTypeShow:             "Show",
+	OpTypeIndexMerge:       "IndexMerge",
+	OpTypeUnion:            "Union",
+	OpTypeWindow:           "Window",
+	OpTypeExchangeSender:   "ExchangeSender",
+	OpTypeExchangeReceiver: "ExchangeReceiver",
+}
+
+// jsonPlan is the JSON encoding of Plan.
+type jsonPlan struct {
+	Schema   string  `json:"schema,omitempty"`
+	SQL      string  `json:"sql"`
+	Ver      PlanVer `json:"ver"`
+	PlanText string  `json:"planText,omitempty"`
+	Root     *jsonOp `json:"root"`
+}
+
+// jsonOp is the JSON encoding of an operator, fields of concrete operators are omitted if empty.
+type jsonOp struct {
+	ID       string        `json:"id"`
+	Type     string        `json:"type"`
+	EstRows  float64       `json:"estRows"`
+	Task     string        `json:"task"`
+	Runtime  *RuntimeStats `json:"runtime,omitempty"`
+	Row      int           `json:"row,omitempty"`
+	Table    string        `json:"table,omitempty"`
+	Index    string        `json:"index,omitempty"`
+	Batch    bool          `json:"batch,omitempty"`
+	FullScan bool          `json:"fullScan,omitempty"`
+	JoinType string        `json:"joinType,omitempty"`
+	Children []*jsonOp     `json:"children,omitempty"`
+}
+
+// MarshalJSON encodes the plan with its whole operator tree.
+func (p Plan) MarshalJSON() ([]byte, error) {
+	root, err := encodeOp(p.Root)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonPlan{Schema: p.Schema, SQL: p.SQL, Ver: p.Ver, PlanText: p.PlanText, Root: root})
+}
+
+// UnmarshalJSON decodes the plan encoded by MarshalJSON.
+func (p *Plan) UnmarshalJSON(data []byte) error {
+	var jp jsonPlan
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+	root, err := decodeOp(jp.Root)
+	if err != nil {
+		return err
+	}
+	*p = Plan{Schema: jp.Schema, SQL: jp.SQL, Ver: jp.Ver, Root: root, PlanText: jp.PlanText}
+	return nil
+}
+
+// MarshalOperator encodes the operator tree rooted at op.
+func MarshalOperator(op Operator) ([]byte, error) {
+	jop, err := encodeOp(op)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jop)
+}
+
+// UnmarshalOperator decodes the operator tree encoded by MarshalOperator.
+func UnmarshalOperator(data []byte) (Operator, error) {
+	var jop *jsonOp
+	if err := json.Unmarshal(data, &jop); err != nil {
+		return nil, err
+	}
+	return decodeOp(jop)
+}
+
+func encodeOp(op Operator) (*jsonOp, error) {
+	if op == nil {
+		return nil, nil
+	}
+	typeName, ok := opTypeNames[op.Type()]
+	if !ok {
+		return nil, errors.Errorf("unknown type %v of operator %v", op.Type(), op.ID())
+	}
+	jop := &jsonOp{
+		ID:      op.ID(),
+		Type:    typeName,
+		EstRows: op.EstRow(),
+		Task:    op.Task().String(),
+		Runtime: op.Runtime(),
+		Row:     op.Row(),
+	}
+	switch v := op.(type) {
+	case TableScanOp:
+		jop.Table, jop.FullScan = v.Table, v.FullScan
+	case IndexScanOp:
+		jop.Table, jop.Index = v.Table, v.Index
+	case PointGetOp:
+		jop.Table, jop.Batch = v.Table, v.Batch
+	}
+	if OpTypeIsJoin(op.Type()) {
+		jop.JoinType = JoinTypeOf(op).String()
+	}
+	for _, child := range op.Children() {
+		jchild, err := encodeOp(child)
+		if err != nil {
+			return nil, err
+		}
+		jop.Children = append(jop.Children, jchild)
+	}
+	return jop, nil
+}
+
+func decodeOp(jop *jsonOp) (Operator, error) {
+	if jop == nil {
+		return nil, nil
+	}
+	opType, ok := OpTypeUnknown, false
+	for t, name := range opTypeNames {
+		if name == jop.Type {
+			opType, ok = t, true
+		}
+	}
+	if !ok {
+		return nil, errors.Errorf("unknown type %v of operator %v", jop.Type, jop.ID)
+	}
+	task, ok := TaskTypeRoot, false
+	for _, t := range []TaskType{TaskTypeRoot, TaskTypeTiKV, TaskTypeTiFlash} {
+		if t.String() == jop.Task {
+			task, ok = t, true
+		}
+	}
+	if !ok {
+		return nil, errors.Errorf("unknown task %v of operator %v", jop.Task, jop.ID)
+	}
+	children := make([]Operator, 0, len(jop.Children))
+	for _, jchild := range jop.Children {
+		child, err := decodeOp(jchild)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	base := BaseOp{
+		id:       jop.ID,
+		opType:   opType,
+		estRow:   jop.EstRows,
+		task:     task,
+		runtime:  jop.Runtime,
+		row:      jop.Row,
+		children: children,
+	}
+
+	joinType := JoinTypeUnknown
+	if jop.JoinType != "" {
+		idx := -1
+		for i, name := range joinTypeNames {
+			if name == jop.JoinType {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			return nil, errors.Errorf("unknown join type %v of operator %v", jop.JoinType, jop.ID)
+		}
+		joinType = JoinType(idx)
+	}
+
+	switch opType {
+	case OpTypeHashJoin:
+		return HashJoinOp{base, joinType}, nil
+	case OpTypeIndexJoin:
+		return IndexJoinOp{base, joinType}, nil
+	case OpTypeMergeJoin:
+		return MergeJoinOp{base, joinType}, nil
+	case OpTypeHashAgg:
+		return HashAggOp{base}, nil
+	case OpTypeStreamAgg:
+		return StreamAggOp{base}, nil
+	case OpTypeSelection:
+		return SelectionOp{base}, nil
+	case OpTypeProjection:
+		return ProjectionOp{base}, nil
+	case OpTypeTableReader:
+		return TableReaderOp{base}, nil
+	case OpTypeTableScan:
+		return TableScanOp{base, jop.Table, jop.FullScan || isFullTableScan(jop.ID, "")}, nil
+	case OpTypeIndexReader:
+		return IndexReaderOp{base}, nil
+	case OpTypeIndexScan:
+		return IndexScanOp{base, jop.Table, jop.Index}, nil
+	case OpTypeIndexLookup:
+		return IndexLookupOp{base}, nil
+	case OpTypePointGet:
+		return PointGetOp{base, jop.Batch, jop.Table}, nil
+	case OpTypeMaxOneRow:
+		return MaxOneRowOp{base}, nil
+	case OpTypeApply:
+		return ApplyOp{base}, nil
+	case OpTypeLimit:
+		return LimitOp{base}, nil
+	case OpTypeSort:
+		return SortOp{base}, nil
+	case OpTypeTopN:
+		return TopNOp{base}, nil
+	case OpTypeTableDual:
+		return TableDual{base}, nil
+	case OpTypeSelectLock:
+		return SelectLock{base}, nil
+	case OpTypeShow:
+		return ShowOp{base}, nil
+	case OpTypeIndexMerge:
+		return IndexMergeOp{base}, nil
+	case OpTypeUnion:
+		return UnionOp{base}, nil
+	case OpTypeWindow:
+		return WindowOp{base}, nil
+	case OpTypeExchangeSender:
+		return ExchangeSenderOp{base}, nil
+	case OpTypeExchangeReceiver:
+		return ExchangeReceiverOp{base}, nil
+	}
+	return nil, errors.Errorf("unknown type %v of operator %v", jop.Type, jop.ID)
+}
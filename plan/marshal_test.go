@@ -0,0 +1,42 @@
+package plan
+
+import (
+	"encoding/json"
+	"strings"
+
+	. "github.com/pingcap/check"
+)
+
+func (s *parseTestSuite) TestMarshalPlan(c *C) {
+	header := []string{"id", "estRows", "actRows", "task", "access object", "execution info", "operator info", "memory", "disk"}
+	p, err := Parse(V5, "select * from t1 left join t2 on t1.a=t2.a where t1.b in (1, 2)", header, [][]string{
+		{"HashJoin_8", "12.50", "2", "root", "", "time:1.2ms, loops:2", "left outer join, equal:[eq(t1.a, t2.a)]", "11.2 KB", "0 Bytes"},
+		{"├─Batch_Point_Get_9(Build)", "2.00", "2", "root", "table:t1, index:b(b)", "time:200µs, loops:2", "keep order:false", "N/A", "N/A"},
+		{"└─IndexLookUp_12(Probe)", "10.00", "10", "root", "", "time:800µs, loops:2", "", "1024 Bytes", "N/A"},
+		{"  ├─IndexFullScan_10(Build)", "10.00", "10", "cop[tikv]", "table:t2, index:a(a)", "tikv_task:{time:0s, loops:1}", "keep order:false", "N/A", "N/A"},
+		{"  └─TableRowIDScan_11(Probe)", "10.00", "10", "cop[tikv]", "table:t2", "tikv_task:{time:0s, loops:1}", "keep order:false", "N/A", "N/A"},
+	})
+	c.Assert(err, IsNil)
+	p.Schema, p.PlanText = "test", "plan text"
+
+	data, err := json.Marshal(p)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(data), `"joinType":"left outer join"`), IsTrue)
+	var decoded Plan
+	c.Assert(json.Unmarshal(data, &decoded), IsNil)
+	c.Assert(decoded, DeepEquals, p)
+	c.Assert(decoded.Root.Children()[0].(PointGetOp).Batch, IsTrue)
+	c.Assert(CompareDetailed(p, decoded).Same(), IsTrue)
+
+	data, err = MarshalOperator(p.Root.Children()[1])
+	c.Assert(err, IsNil)
+	op, err := UnmarshalOperator(data)
+	c.Assert(err, IsNil)
+	c.Assert(op, DeepEquals, p.Root.Children()[1])
+	c.Assert(op.Children()[0].(IndexScanOp).Index, Equals, "a")
+
+	_, err = UnmarshalOperator([]byte(`{"id":"Foo_1","type":"Foo","task":"root"}`))
+	c.Assert(err, NotNil)
+	_, err = UnmarshalOperator([]byte(`{"id":"HashJoin_1","type":"HashJoin","task":"root","joinType":"full join"}`))
+	c.Assert(err, NotNil)
+}
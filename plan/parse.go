@@ -1,7 +1,7 @@
 package plan
 
 import (
-	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/pingcap/errors"
@@ -18,50 +18,44 @@ func ParseText(sql, explainText, version string) (Plan, error) {
 	sql = strings.TrimSpace(sql)
 	sql = strings.TrimSuffix(sql, ";")
 	ver := formatVersion(version)
+	header := splitRows(explainLines[1:2])[0]
 	rows := splitRows(explainLines[3 : len(explainLines)-1])
-	return Parse(ver, sql, rows)
+	return Parse(ver, sql, header, rows)
 }
 
-func Parse(version, sql string, explainRows [][]string) (_ Plan, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			explainContent := ""
-			for _, row := range explainRows {
-				explainContent += strings.Join(row, "\t") + "\n"
-			}
-			fmt.Printf("parse sql=%v ver=%v panic\n, explain: %v\n\n", sql, version, explainContent)
-			panic(r)
+// Parse parses the explain rows, columns are located by their names in the header,
+// a nil header means the default column layout of the version.
+func Parse(version, sql string, header []string, explainRows [][]string) (_ Plan, err error) {
+	defer recoverParse(sql, version, func() string {
+		explainContent := ""
+		for _, row := range explainRows {
+			explainContent += strings.Join(row, "\t") + "\n"
 		}
-	}()
+		return explainContent
+	}, &err)
 
 	switch formatVersion(version) {
 	case V2:
-		return ParseV2(sql, explainRows)
+		return ParseV2(sql, header, explainRows)
 	case V3:
-		return ParseV3(sql, explainRows)
+		return ParseV3(sql, header, explainRows)
 	case V4:
-		return ParseV4(sql, explainRows)
+		return ParseV4(sql, header, explainRows)
+	case V5:
+		return ParseV5(sql, header, explainRows)
+	case V6:
+		return ParseV6(sql, header, explainRows)
+	case V7:
+		return ParseV7(sql, header, explainRows)
 	}
 	return Plan{}, errors.Errorf("unsupported TiDB version %v", version)
 }
 
-func Compare(p1, p2 Plan) (reason string, same bool) {
-	if p1.SQL != p2.SQL {
-		return "differentiate SQLs", false
-	}
-	p1.Root = removeProj(p1.Root)
-	p2.Root = removeProj(p2.Root)
-	return compare(p1.Root, p2.Root, fillInAlias(p1.SQL))
-}
-
-func removeProj(node Operator) Operator {
-	if node.Type() == OpTypeProjection {
-		return node.Children()[0]
+// recoverParse turns a panic caused by a malformed plan into an error, it must be deferred directly.
+func recoverParse(sql, version string, content func() string, err *error) {
+	if r := recover(); r != nil {
+		*err = errors.Errorf("parse sql=%v ver=%v panic: %v, explain: %v", sql, version, r, content())
 	}
-	for i, child := range node.Children() {
-		node.SetChild(i, removeProj(child))
-	}
-	return node
 }
 
 type aliasVisitor struct {
@@ -128,44 +122,6 @@ func specialHandlePointGet(op1, op2 Operator, tblAlias map[string]string) bool {
 	return false
 }
 
-func compare(op1, op2 Operator, tblAlias map[string]string) (reason string, same bool) {
-	if specialHandlePointGet(op1, op2, tblAlias) {
-		return "", true
-	}
-
-	if op1.Type() != op2.Type() || op1.Task() != op2.Task() {
-		return fmt.Sprintf("different operators %v and %v", op1.ID(), op2.ID()), false
-	}
-	c1, c2 := op1.Children(), op2.Children()
-	if len(c1) != len(c2) {
-		return fmt.Sprintf("%v and %v have different children lengths", op1.ID(), op2.ID()), false
-	}
-	same = true
-	switch op1.Type() {
-	case OpTypeTableScan:
-		t1, t2 := op1.(TableScanOp), op2.(TableScanOp)
-		if !sameTable(t1.Table, t2.Table, tblAlias) {
-			same = false
-			reason = fmt.Sprintf("different table scan %v:%v, %v:%v", t1.ID(), t1.Table, t2.ID(), t2.Table)
-		}
-	case OpTypeIndexScan:
-		t1, t2 := op1.(IndexScanOp), op2.(IndexScanOp)
-		if !sameTable(t1.Table, t2.Table, tblAlias) || t1.Index != t2.Index {
-			same = false
-			reason = fmt.Sprintf("different index scan %v:%v:%v, %v:%v:%v", t1.ID(), t1.Table, t1.Index, t2.ID(), t2.Table, t2.Index)
-		}
-	}
-	if !same {
-		return reason, false
-	}
-	for i := range c1 {
-		if reason, same = compare(c1[i], c2[i], tblAlias); !same {
-			return reason, same
-		}
-	}
-	return "", true
-}
-
 func sameTable(t1, t2 string, alias map[string]string) bool {
 	if alias == nil {
 		alias = make(map[string]string)
@@ -217,15 +173,90 @@ func isSeparateLine(line string) bool {
 }
 
 func formatVersion(version string) string {
-	version = strings.ToLower(version)
-	if strings.HasPrefix(version, V2) {
-		return V2
-	} else if strings.HasPrefix(version, V3) {
-		return V3
+	version = strings.ToLower(strings.TrimSpace(version))
+	for _, ver := range []string{V2, V3, V4, V5, V6, V7} {
+		if strings.HasPrefix(version, ver) {
+			return ver
+		}
+	}
+	// versions newer than v7 still use the v7 layout
+	if major, err := strconv.Atoi(strings.Split(strings.TrimPrefix(version, "v"), ".")[0]); err == nil && major > 7 {
+		return V7
 	}
 	return V4
 }
 
+const (
+	colID           = "id"
+	colEstRows      = "estrows"
+	colCount        = "count"
+	colTask         = "task"
+	colAccessObject = "access object"
+	colOperatorInfo = "operator info"
+)
+
+// explainColumns maps the lower-cased column names of an explain result to their positions.
+type explainColumns map[string]int
+
+// parseHeader builds the column map from the header, defaultHeader is used if the header is empty.
+func parseHeader(header, defaultHeader []string) (explainColumns, error) {
+	if len(header) == 0 {
+		header = defaultHeader
+	}
+	cols := make(explainColumns, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols[colID]; !ok {
+		return nil, errors.Errorf("cannot find the id column in header %v", header)
+	}
+	return cols, nil
+}
+
+// field returns the trimmed value of the first existing column in names.
+func (c explainColumns) field(row []string, names ...string) string {
+	for _, name := range names {
+		if i, ok := c[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+	}
+	return ""
+}
+
+func parseEstRows(estRows string) (float64, error) {
+	estRows = strings.TrimSpace(estRows)
+	if estRows == "" || strings.ToUpper(estRows) == "N/A" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(estRows, 64)
+}
+
+// SplitDecodedPlan splits the plan text decoded by TiDB, like plans in slow logs and the statement summary,
+// into the header and rows, whose columns are separated by tabs.
+func SplitDecodedPlan(planText string) ([]string, [][]string, error) {
+	var header []string
+	var rows [][]string
+	for _, line := range strings.Split(planText, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		cols := strings.Split(strings.TrimPrefix(line, "\t"), "\t")
+		for i := range cols {
+			// leading spaces of IDs are a part of the tree structure
+			cols[i] = strings.TrimRight(cols[i], " ")
+		}
+		if header == nil {
+			header = cols
+			continue
+		}
+		rows = append(rows, cols)
+	}
+	if len(rows) == 0 {
+		return nil, nil, errors.Errorf("no operator in the plan %v", planText)
+	}
+	return header, rows, nil
+}
+
 func splitRows(rows []string) [][]string {
 	results := make([][]string, 0, len(rows))
 	for _, row := range rows {
@@ -282,6 +313,15 @@ func splitKVs(kvStr string) map[string]string {
 	return kvMap
 }
 
+// isFullTableScan returns whether a table scan reads the whole table, by its operator ID
+// like TableFullScan in v4+, or its range in the operator info like `range:[-inf,+inf]` in v2/v3.
+func isFullTableScan(opID, operatorInfo string) bool {
+	if strings.Contains(strings.ToLower(opID), "fullscan") {
+		return true
+	}
+	return strings.Contains(strings.ReplaceAll(operatorInfo, " ", ""), "range:[-inf,+inf]")
+}
+
 func extractIndexColumns(indexStr string) string {
 	be := strings.Index(indexStr, "(")
 	ed := strings.Index(indexStr, ")")
@@ -302,6 +342,31 @@ func parseTaskType(taskStr string) TaskType {
 	return TaskTypeTiKV
 }
 
+// joinTypePrefixes is ordered so that longer names are matched first.
+var joinTypePrefixes = []struct {
+	prefix   string
+	joinType JoinType
+}{
+	{"anti left outer semi join", JoinTypeAntiLeftOuterSemi},
+	{"left outer semi join", JoinTypeLeftOuterSemi},
+	{"anti semi join", JoinTypeAntiSemi},
+	{"semi join", JoinTypeSemi},
+	{"left outer join", JoinTypeLeftOuter},
+	{"right outer join", JoinTypeRightOuter},
+	{"inner join", JoinTypeInner},
+}
+
+// parseJoinType extracts the join type from operator info like "inner join, equal:[eq(t1.a, t2.a)]".
+func parseJoinType(info string) JoinType {
+	info = strings.TrimSpace(strings.ToLower(info))
+	for _, p := range joinTypePrefixes {
+		if strings.HasPrefix(info, p.prefix) {
+			return p.joinType
+		}
+	}
+	return JoinTypeUnknown
+}
+
 func MatchOpType(opID string) OpType {
 	x := strings.ToLower(opID)
 	if strings.Contains(x, "agg") {
@@ -313,12 +378,13 @@ func MatchOpType(opID string) OpType {
 		return OpTypeUnknown
 	}
 	if strings.Contains(x, "join") {
-		if strings.Contains(x, "hash") {
+		// IndexHashJoin and IndexMergeJoin are variants of IndexJoin
+		if strings.Contains(x, "index") {
+			return OpTypeIndexJoin
+		} else if strings.Contains(x, "hash") {
 			return OpTypeHashJoin
 		} else if strings.Contains(x, "merge") {
 			return OpTypeMergeJoin
-		} else if strings.Contains(x, "index") {
-			return OpTypeIndexJoin
 		}
 		return OpTypeUnknown
 	}
@@ -339,6 +405,16 @@ func MatchOpType(opID string) OpType {
 			return OpTypeIndexScan
 		} else if strings.Contains(x, "lookup") {
 			return OpTypeIndexLookup
+		} else if strings.Contains(x, "merge") {
+			return OpTypeIndexMerge
+		}
+		return OpTypeUnknown
+	}
+	if strings.Contains(x, "exchange") {
+		if strings.Contains(x, "sender") {
+			return OpTypeExchangeSender
+		} else if strings.Contains(x, "receiver") {
+			return OpTypeExchangeReceiver
 		}
 		return OpTypeUnknown
 	}
@@ -372,6 +448,12 @@ func MatchOpType(opID string) OpType {
 	if strings.Contains(x, "show") {
 		return OpTypeShow
 	}
+	if strings.Contains(x, "union") {
+		return OpTypeUnion
+	}
+	if strings.Contains(x, "window") {
+		return OpTypeWindow
+	}
 	return OpTypeUnknown
 }
 
@@ -0,0 +1,118 @@
+package plan
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// jsonOperator is an operator in the result of EXPLAIN FORMAT='tidb_json'.
+type jsonOperator struct {
+	ID           string          `json:"id"`
+	EstRows      string          `json:"estRows"`
+	ActRows      string          `json:"actRows"`
+	TaskType     string          `json:"taskType"`
+	AccessObject string          `json:"accessObject"`
+	ExecuteInfo  string          `json:"executeInfo"`
+	OperatorInfo string          `json:"operatorInfo"`
+	MemoryInfo   string          `json:"memoryInfo"`
+	DiskInfo     string          `json:"diskInfo"`
+	SubOperators []*jsonOperator `json:"subOperators"`
+}
+
+// jsonHeader is the column layout used to parse JSON operators by the row parser.
+var jsonHeader = []string{"id", "estRows", "task", "access object", "operator info"}
+
+// SupportJSONFormat returns whether TiDB of this version supports EXPLAIN FORMAT='tidb_json'.
+func SupportJSONFormat(version string) bool {
+	major, minor, ok := majorMinorVersion(version)
+	if !ok {
+		return false
+	}
+	return major > 6 || (major == 6 && minor >= 5)
+}
+
+// ParseJSON parses the result of EXPLAIN FORMAT='tidb_json'.
+func ParseJSON(version, sql, jsonText string) (_ Plan, err error) {
+	defer recoverParse(sql, version, func() string { return jsonText }, &err)
+	roots, err := unmarshalJSONPlan(jsonText)
+	if err != nil {
+		return Plan{}, err
+	}
+	cols, err := parseHeader(jsonHeader, nil)
+	if err != nil {
+		return Plan{}, err
+	}
+	p := Plan{SQL: sql, Ver: PlanVer(formatVersion(version))}
+	// other roots are CTE definitions, only the main query is kept
+	rowNo := 0
+	root, err := parseJSONOp(cols, roots[0], &rowNo)
+	p.Root = root
+	return p, err
+}
+
+// parseJSONOp numbers operators in pre-order, which is the order of rows in the table format.
+func parseJSONOp(cols explainColumns, op *jsonOperator, rowNo *int) (Operator, error) {
+	no := *rowNo
+	*rowNo++
+	children := make([]Operator, 0, len(op.SubOperators))
+	for _, sub := range op.SubOperators {
+		child, err := parseJSONOp(cols, sub, rowNo)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	row := []string{op.ID, op.EstRows, op.TaskType, op.AccessObject, op.OperatorInfo}
+	return parseRowV4(cols, no, row, children)
+}
+
+// JSONToExplainRows converts the result of EXPLAIN FORMAT='tidb_json' to the
+// header and rows of the corresponding table format.
+func JSONToExplainRows(jsonText string) ([]string, [][]string, error) {
+	roots, err := unmarshalJSONPlan(jsonText)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := append([]string(nil), jsonHeader...)
+	var rows [][]string
+	var walk func(op *jsonOperator, prefix, childPrefix string)
+	walk = func(op *jsonOperator, prefix, childPrefix string) {
+		rows = append(rows, []string{prefix + op.ID, op.EstRows, op.TaskType, op.AccessObject, op.OperatorInfo})
+		for i, sub := range op.SubOperators {
+			if i == len(op.SubOperators)-1 {
+				walk(sub, childPrefix+"└─", childPrefix+"  ")
+			} else {
+				walk(sub, childPrefix+"├─", childPrefix+"│ ")
+			}
+		}
+	}
+	for _, root := range roots {
+		walk(root, "", "")
+	}
+	return header, rows, nil
+}
+
+func unmarshalJSONPlan(jsonText string) ([]*jsonOperator, error) {
+	var roots []*jsonOperator
+	if err := json.Unmarshal([]byte(strings.TrimSpace(jsonText)), &roots); err != nil {
+		return nil, errors.Annotate(err, "invalid json plan")
+	}
+	if len(roots) == 0 {
+		return nil, errors.New("empty json plan")
+	}
+	return roots, nil
+}
+
+func majorMinorVersion(version string) (major, minor int, ok bool) {
+	fields := strings.Split(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v"), ".")
+	if len(fields) < 2 {
+		return 0, 0, false
+	}
+	var err1, err2 error
+	major, err1 = strconv.Atoi(fields[0])
+	minor, err2 = strconv.Atoi(fields[1])
+	return major, minor, err1 == nil && err2 == nil
+}
@@ -0,0 +1,98 @@
+package plan
+
+import (
+	"strings"
+
+	. "github.com/pingcap/check"
+)
+
+var explainJSONResult = `[
+  {
+    "id": "HashJoin_8",
+    "estRows": "12487.50",
+    "taskType": "root",
+    "operatorInfo": "inner join, equal:[eq(test.t1.a, test.t2.a)]",
+    "subOperators": [
+      {
+        "id": "TableReader_15(Build)",
+        "estRows": "9990.00",
+        "taskType": "root",
+        "operatorInfo": "data:Selection_14",
+        "subOperators": [
+          {
+            "id": "Selection_14",
+            "estRows": "9990.00",
+            "taskType": "cop[tikv]",
+            "operatorInfo": "not(isnull(test.t2.a))",
+            "subOperators": [
+              {
+                "id": "TableFullScan_13",
+                "estRows": "10000.00",
+                "taskType": "cop[tikv]",
+                "accessObject": "table:t2",
+                "operatorInfo": "keep order:false, stats:pseudo"
+              }
+            ]
+          }
+        ]
+      },
+      {
+        "id": "IndexReader_12(Probe)",
+        "estRows": "9990.00",
+        "taskType": "root",
+        "operatorInfo": "index:IndexFullScan_11",
+        "subOperators": [
+          {
+            "id": "IndexFullScan_11",
+            "estRows": "9990.00",
+            "taskType": "cop[tikv]",
+            "accessObject": "table:t1, index:ia(a)",
+            "operatorInfo": "keep order:false, stats:pseudo"
+          }
+        ]
+      }
+    ]
+  }
+]`
+
+func (s *parseTestSuite) TestParseJSON(c *C) {
+	p, err := ParseJSON("v7.1.0", "", explainJSONResult)
+	c.Assert(err, IsNil)
+	c.Assert(p.Ver, Equals, PlanVer(V7))
+	c.Assert(p.Root.Type(), Equals, OpTypeHashJoin)
+	c.Assert(p.Root.EstRow(), Equals, 12487.5)
+	scan := p.Root.Children()[0].Children()[0].Children()[0].(TableScanOp)
+	c.Assert(scan.Table, Equals, "t2")
+	idx := p.Root.Children()[1].Children()[0].(IndexScanOp)
+	c.Assert(idx.Table, Equals, "t1")
+	c.Assert(idx.Index, Equals, "a")
+	c.Assert(idx.Task(), Equals, TaskTypeTiKV)
+
+	_, err = ParseJSON("v7.1.0", "", "[]")
+	c.Assert(err, NotNil)
+
+	// a join with only one child is malformed
+	malformedJoin := `[{"id": "HashJoin_8", "estRows": "1.00", "taskType": "root", "operatorInfo": "inner join",
+  "subOperators": [{"id": "TableDual_5(Probe)", "estRows": "1.00", "taskType": "root"}]}]`
+	_, err = ParseJSON("v7.1.0", "", malformedJoin)
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "panic"), IsTrue)
+}
+
+func (s *parseTestSuite) TestJSONToExplainRows(c *C) {
+	header, rows, err := JSONToExplainRows(explainJSONResult)
+	c.Assert(err, IsNil)
+	p1, err := ParseJSON(V7, "", explainJSONResult)
+	c.Assert(err, IsNil)
+	p2, err := Parse(V7, "", header, rows)
+	c.Assert(err, IsNil)
+	c.Assert(p2.Format(), Equals, p1.Format())
+	c.Assert(rows[3][0], Equals, "│   └─TableFullScan_13")
+}
+
+func (s *parseTestSuite) TestSupportJSONFormat(c *C) {
+	c.Assert(SupportJSONFormat("v6.1.0"), IsFalse)
+	c.Assert(SupportJSONFormat("v6.5.2"), IsTrue)
+	c.Assert(SupportJSONFormat("v7.5.0"), IsTrue)
+	c.Assert(SupportJSONFormat("nightly"), IsFalse)
+}
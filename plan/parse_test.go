@@ -452,3 +452,25 @@ func (s *parseTestSuite) TestFormatExplainRows(c *C) {
 	rows := splitRows(explainLines[3 : len(explainLines)-1])
 	fmt.Println(FormatExplainRows(rows))
 }
+
+func (s *parseTestSuite) TestSplitDecodedPlan(c *C) {
+	planText := "\tid                  \ttask     \testRows\toperator info\n" +
+		"\tTableReader_7       \troot     \t10.00  \tdata:Selection_6\n" +
+		"\t└─Selection_6       \tcop[tikv]\t10.00  \teq(test.t.b, 10)\n" +
+		"\t  └─TableFullScan_5 \tcop[tikv]\t10000  \ttable:t, keep order:false\n"
+	header, rows, err := SplitDecodedPlan(planText)
+	c.Assert(err, IsNil)
+	c.Assert(header, DeepEquals, []string{"id", "task", "estRows", "operator info"})
+	c.Assert(rows, HasLen, 3)
+	c.Assert(rows[2][0], Equals, "  └─TableFullScan_5")
+
+	p, err := Parse(V4, "select * from t where b = 10", header, rows)
+	c.Assert(err, IsNil)
+	c.Assert(p.Root.Type(), Equals, OpTypeTableReader)
+	scan := p.Root.Children()[0].Children()[0].(TableScanOp)
+	c.Assert(scan.Table, Equals, "t")
+	c.Assert(scan.EstRow(), Equals, 10000.0)
+
+	_, _, err = SplitDecodedPlan("\tid\ttask\testRows\toperator info\n")
+	c.Assert(err, NotNil)
+}
@@ -2,71 +2,84 @@ package plan
 
 import (
 	"github.com/pingcap/errors"
-	"strconv"
 	"strings"
 )
 
-func ParseV3(SQL string, rows [][]string) (Plan, error) {
+// defaultHeaderV3 is the column layout of EXPLAIN in v3, it's used when no header is provided.
+var defaultHeaderV3 = []string{"id", "count", "task", "operator info"}
+
+func ParseV3(SQL string, header []string, rows [][]string) (Plan, error) {
+	cols, err := parseHeader(header, defaultHeaderV3)
+	if err != nil {
+		return Plan{}, err
+	}
 	p := Plan{SQL: SQL, Ver: V3}
-	root, err := parseV3Op(rows, 0)
+	root, err := parseV3Op(cols, rows, 0)
 	p.Root = root
 	return p, err
 }
 
-func parseV3Op(rows [][]string, rowNo int) (Operator, error) {
+func parseV3Op(cols explainColumns, rows [][]string, rowNo int) (Operator, error) {
 	children := make([]Operator, 0, 2)
-	childRowNo := findChildRowNo(rows, rowNo, 0)
+	childRowNo := findChildRowNo(rows, rowNo, cols[colID])
 	for _, no := range childRowNo {
-		child, err := parseV3Op(rows, no)
+		child, err := parseV3Op(cols, rows, no)
 		if err != nil {
 			return nil, err
 		}
 		children = append(children, child)
 	}
 
-	op, err := parseLineV3(rows[rowNo], children)
+	op, err := parseLineV3(cols, rowNo, rows[rowNo], children)
 	if err != nil {
 		return nil, err
 	}
 	return op, nil
 }
 
-func parseLineV3(cols []string, children []Operator) (Operator, error) {
-	estRows, err := strconv.ParseFloat(strings.TrimSpace(cols[1]), 64)
+func parseLineV3(cols explainColumns, rowNo int, row []string, children []Operator) (Operator, error) {
+	estRows, err := parseEstRows(cols.field(row, colEstRows, colCount))
 	if err != nil {
 		return nil, err
 	}
-	opID := extractOperatorID(cols[0])
+	opID := extractOperatorID(row[cols[colID]])
 	opType := MatchOpType(opID)
 	if OpTypeIsJoin(opType) {
-		if err := adjustJoinChildrenV3(cols[3], children); err != nil {
+		if err := adjustJoinChildrenV3(cols.field(row, colOperatorInfo), children); err != nil {
 			return nil, err
 		}
 	}
+	runtime, err := parseRuntimeStats(cols, row)
+	if err != nil {
+		return nil, err
+	}
 	base := BaseOp{
 		id:       opID,
 		opType:   opType,
 		estRow:   estRows,
-		task:     parseTaskType(cols[2]),
+		task:     parseTaskType(cols.field(row, colTask)),
+		runtime:  runtime,
+		row:      rowNo,
 		children: children,
 	}
 
+	accessObject := cols.field(row, colAccessObject, colOperatorInfo)
 	switch opType {
 	case OpTypeHashJoin:
-		return HashJoinOp{base, JoinTypeUnknown}, nil
+		return HashJoinOp{base, parseJoinType(cols.field(row, colOperatorInfo))}, nil
 	case OpTypeMergeJoin:
-		return MergeJoinOp{base, JoinTypeUnknown}, nil
+		return MergeJoinOp{base, parseJoinType(cols.field(row, colOperatorInfo))}, nil
 	case OpTypeIndexJoin:
-		return IndexJoinOp{base, JoinTypeUnknown}, nil
+		return IndexJoinOp{base, parseJoinType(cols.field(row, colOperatorInfo))}, nil
 	case OpTypeTableReader:
 		return TableReaderOp{base}, nil
 	case OpTypeTableScan:
-		kvs := splitKVs(cols[3])
-		return TableScanOp{base, kvs["table"]}, nil
+		kvs := splitKVs(accessObject)
+		return TableScanOp{base, kvs["table"], isFullTableScan(opID, cols.field(row, colOperatorInfo))}, nil
 	case OpTypeIndexReader:
 		return IndexReaderOp{base}, nil
 	case OpTypeIndexScan:
-		tbl, idx := extractTableIndexV3(cols[3])
+		tbl, idx := extractTableIndexV3(accessObject)
 		return IndexScanOp{base, tbl, idx}, nil
 	case OpTypeIndexLookup:
 		return IndexLookupOp{base}, nil
@@ -75,7 +88,7 @@ func parseLineV3(cols []string, children []Operator) (Operator, error) {
 	case OpTypeProjection:
 		return ProjectionOp{base}, nil
 	case OpTypePointGet:
-		kvs := splitKVs(cols[3])
+		kvs := splitKVs(accessObject)
 		return PointGetOp{base, false, kvs["table"]}, nil
 	case OpTypeHashAgg:
 		return HashAggOp{base}, nil
@@ -1,80 +1,103 @@
 package plan
 
 import (
-	"github.com/pingcap/errors"
-	"strconv"
 	"strings"
+
+	"github.com/pingcap/errors"
 )
 
-func ParseV4(SQL string, rows [][]string) (Plan, error) {
-	p := Plan{SQL: SQL, Ver: V4}
-	root, err := parseV4Op(rows, 0)
+// defaultHeaderV4 is the column layout of EXPLAIN in v4, it's used when no header is provided.
+var defaultHeaderV4 = []string{"id", "estRows", "task", "access object", "operator info"}
+
+func ParseV4(SQL string, header []string, rows [][]string) (Plan, error) {
+	return parseWithHeader(V4, defaultHeaderV4, SQL, header, rows)
+}
+
+// parseWithHeader parses plans of v4 and later versions, which only differ in their default headers.
+func parseWithHeader(ver PlanVer, defaultHeader []string, SQL string, header []string, rows [][]string) (Plan, error) {
+	cols, err := parseHeader(header, defaultHeader)
+	if err != nil {
+		return Plan{}, err
+	}
+	p := Plan{SQL: SQL, Ver: ver}
+	root, err := parseV4Op(cols, rows, 0)
 	p.Root = root
 	return p, err
 }
 
-func parseV4Op(rows [][]string, rowNo int) (Operator, error) {
+func parseV4Op(cols explainColumns, rows [][]string, rowNo int) (Operator, error) {
 	children := make([]Operator, 0, 2)
-	childRowNo := findChildRowNo(rows, rowNo, 0)
+	childRowNo := findChildRowNo(rows, rowNo, cols[colID])
 	for _, no := range childRowNo {
-		child, err := parseV4Op(rows, no)
+		child, err := parseV4Op(cols, rows, no)
 		if err != nil {
 			return nil, err
 		}
 		children = append(children, child)
 	}
 
-	op, err := parseRowV4(rows[rowNo], children)
+	op, err := parseRowV4(cols, rowNo, rows[rowNo], children)
 	if err != nil {
 		return nil, err
 	}
 	return op, nil
 }
 
-func parseRowV4(cols []string, children []Operator) (Operator, error) {
-	estRows, err := strconv.ParseFloat(strings.TrimSpace(cols[1]), 64)
+// parseRowV4 parses a row of EXPLAIN since v4 and an operator of EXPLAIN FORMAT='tidb_json',
+// whose columns are located by the header.
+func parseRowV4(cols explainColumns, rowNo int, row []string, children []Operator) (Operator, error) {
+	estRows, err := parseEstRows(cols.field(row, colEstRows, colCount))
 	if err != nil {
 		return nil, err
 	}
-	opID := extractOperatorID(cols[0])
+	opID := extractOperatorID(row[cols[colID]])
 	opType := MatchOpType(opID)
 	if OpTypeIsJoin(opType) {
 		adjustJoinChildrenV4(children)
 	}
+	runtime, err := parseRuntimeStats(cols, row)
+	if err != nil {
+		return nil, err
+	}
 	base := BaseOp{
 		id:       opID,
 		opType:   opType,
 		estRow:   estRows,
-		task:     parseTaskType(cols[2]),
+		task:     parseTaskType(cols.field(row, colTask)),
+		runtime:  runtime,
+		row:      rowNo,
 		children: children,
 	}
 
+	accessObject := cols.field(row, colAccessObject, colOperatorInfo)
 	switch opType {
 	case OpTypeHashJoin:
-		return HashJoinOp{base, JoinTypeUnknown}, nil
+		return HashJoinOp{base, parseJoinType(cols.field(row, colOperatorInfo))}, nil
 	case OpTypeMergeJoin:
-		return MergeJoinOp{base, JoinTypeUnknown}, nil
+		return MergeJoinOp{base, parseJoinType(cols.field(row, colOperatorInfo))}, nil
 	case OpTypeIndexJoin:
-		return IndexJoinOp{base, JoinTypeUnknown}, nil
+		return IndexJoinOp{base, parseJoinType(cols.field(row, colOperatorInfo))}, nil
 	case OpTypeTableReader:
 		return TableReaderOp{base}, nil
 	case OpTypeTableScan:
-		kvs := splitKVs(cols[3])
-		return TableScanOp{base, kvs["table"]}, nil
+		kvs := splitKVs(accessObject)
+		return TableScanOp{base, kvs["table"], isFullTableScan(opID, cols.field(row, colOperatorInfo))}, nil
 	case OpTypeIndexReader:
 		return IndexReaderOp{base}, nil
 	case OpTypeIndexScan:
-		tbl, idx := extractTableIndexV4(cols[3])
+		tbl, idx := extractTableIndexV4(accessObject)
 		return IndexScanOp{base, tbl, idx}, nil
 	case OpTypeIndexLookup:
 		return IndexLookupOp{base}, nil
+	case OpTypeIndexMerge:
+		return IndexMergeOp{base}, nil
 	case OpTypeSelection:
 		return SelectionOp{base}, nil
 	case OpTypeProjection:
 		return ProjectionOp{base}, nil
 	case OpTypePointGet:
-		kvs := splitKVs(cols[3])
-		return PointGetOp{base, false, kvs["table"]}, nil
+		kvs := splitKVs(accessObject)
+		return PointGetOp{base, strings.Contains(strings.ToLower(opID), "batch"), kvs["table"]}, nil
 	case OpTypeHashAgg:
 		return HashAggOp{base}, nil
 	case OpTypeStreamAgg:
@@ -95,6 +118,14 @@ func parseRowV4(cols []string, children []Operator) (Operator, error) {
 		return SelectLock{base}, nil
 	case OpTypeShow:
 		return ShowOp{base}, nil
+	case OpTypeUnion:
+		return UnionOp{base}, nil
+	case OpTypeWindow:
+		return WindowOp{base}, nil
+	case OpTypeExchangeSender:
+		return ExchangeSenderOp{base}, nil
+	case OpTypeExchangeReceiver:
+		return ExchangeReceiverOp{base}, nil
 	}
 	return nil, errors.Errorf("unknown operator type %v", opID)
 }
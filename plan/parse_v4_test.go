@@ -99,3 +99,54 @@ func (s *parseTestSuite) TestParseSelectLockV4(c *C) {
 	c.Assert(plan.Root.ID(), Equals, "Projection_5")
 	c.Assert(plan.Root.Children()[0].Type(), Equals, OpTypeSelectLock)
 }
+
+func (s *parseTestSuite) TestParseExplainAnalyzeV4(c *C) {
+	p := `
++-----------------------------+----------+---------+-----------+---------------------+----------------------------------+-------------------------------+-----------+------+
+| id                          | estRows  | actRows | task      | access object       | execution info                   | operator info                 | memory    | disk |
++-----------------------------+----------+---------+-----------+---------------------+----------------------------------+-------------------------------+-----------+------+
+| IndexLookUp_10              | 10.00    | 3       | root      |                     | time:1.2ms, loops:2              |                               | 11.2 KB   | N/A  |
+| ├─IndexRangeScan_8(Build)   | 10.00    | 3       | cop[tikv] | table:t, index:b(b) | tikv_task:{time:0s, loops:1}     | range:[10,10], keep order:false | N/A     | N/A  |
+| └─TableRowIDScan_9(Probe)   | 10.00    | 3       | cop[tikv] | table:t             | tikv_task:{time:0s, loops:1}     | keep order:false              | N/A       | N/A  |
++-----------------------------+----------+---------+-----------+---------------------+----------------------------------+-------------------------------+-----------+------+
+`
+	plan, err := ParseText("", p, V4)
+	c.Assert(err, IsNil)
+	c.Assert(plan.Root.Type(), Equals, OpTypeIndexLookup)
+	idx := plan.Root.Children()[0].(IndexScanOp)
+	c.Assert(idx.Table, Equals, "t")
+	c.Assert(idx.Index, Equals, "b")
+	c.Assert(idx.Task(), Equals, TaskTypeTiKV)
+	scan := plan.Root.Children()[1].(TableScanOp)
+	c.Assert(scan.Table, Equals, "t")
+}
+
+func (s *parseTestSuite) TestParseReorderedColumnsV4(c *C) {
+	header := []string{"task", "operator info", "id", "access object", "estRows"}
+	rows := [][]string{
+		{"root", "data:TableFullScan_5", "TableReader_6", "", "10000.00"},
+		{"cop[tikv]", "keep order:false", "└─TableFullScan_5", "table:t", "10000.00"},
+	}
+	plan, err := Parse(V4, "", header, rows)
+	c.Assert(err, IsNil)
+	c.Assert(plan.Root.ID(), Equals, "TableReader_6")
+	c.Assert(plan.Root.EstRow(), Equals, 10000.0)
+	c.Assert(plan.Root.Children()[0].(TableScanOp).Table, Equals, "t")
+}
+
+func (s *parseTestSuite) TestParseIndexMergeAndUnionV4(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	rows := [][]string{
+		{"Union_8", "20.00", "root", "", ""},
+		{"├─IndexMerge_11", "10.00", "root", "", ""},
+		{"│ ├─IndexRangeScan_8(Build)", "10.00", "cop[tikv]", "table:t, index:a(a)", "range:[1,1], keep order:false"},
+		{"│ ├─IndexRangeScan_9(Build)", "10.00", "cop[tikv]", "table:t, index:b(b)", "range:[1,1], keep order:false"},
+		{"│ └─TableRowIDScan_10(Probe)", "10.00", "cop[tikv]", "table:t", "keep order:false"},
+		{"└─Batch_Point_Get_12", "10.00", "root", "table:t", "handle:[1 2]"},
+	}
+	plan, err := Parse(V4, "", header, rows)
+	c.Assert(err, IsNil)
+	c.Assert(plan.Root.Type(), Equals, OpTypeUnion)
+	c.Assert(plan.Root.Children()[0].Type(), Equals, OpTypeIndexMerge)
+	c.Assert(plan.Root.Children()[1].(PointGetOp).Batch, IsTrue)
+}
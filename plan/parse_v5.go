@@ -0,0 +1,18 @@
+package plan
+
+// defaultHeaderV5 is the column layout of EXPLAIN since v5, it's used when no header is provided.
+var defaultHeaderV5 = []string{"id", "estRows", "task", "access object", "operator info"}
+
+func ParseV5(SQL string, header []string, rows [][]string) (Plan, error) {
+	return parseWithHeader(V5, defaultHeaderV5, SQL, header, rows)
+}
+
+// ParseV6 parses plans of v6, which keep the v5 layout but may have extra columns like estCost.
+func ParseV6(SQL string, header []string, rows [][]string) (Plan, error) {
+	return parseWithHeader(V6, defaultHeaderV5, SQL, header, rows)
+}
+
+// ParseV7 parses plans of v7, whose operator IDs may have no `_N` suffix in the brief format.
+func ParseV7(SQL string, header []string, rows [][]string) (Plan, error) {
+	return parseWithHeader(V7, defaultHeaderV5, SQL, header, rows)
+}
@@ -0,0 +1,91 @@
+package plan
+
+import (
+	. "github.com/pingcap/check"
+)
+
+func (s *parseTestSuite) TestParseV5(c *C) {
+	result := `
++-------------------------------+---------+-----------+----------------------------------+-----------------------------------------------+
+| id                            | estRows | task      | access object                    | operator info                                 |
++-------------------------------+---------+-----------+----------------------------------+-----------------------------------------------+
+| IndexHashJoin_14              | 12.49   | root      |                                  | inner join, inner:IndexLookUp_11, equal:[...] |
+| ├─TableReader_25(Build)       | 9.99    | root      |                                  | data:Selection_24                             |
+| │ └─Selection_24              | 9.99    | cop[tikv] |                                  | eq(test.t1.b, 10)                             |
+| │   └─TableFullScan_23        | 10000.00| cop[tikv] | table:t1                         | keep order:false, stats:pseudo                |
+| └─IndexLookUp_11(Probe)       | 1.25    | root      |                                  |                                               |
+|   ├─IndexRangeScan_9(Build)   | 1.25    | cop[tikv] | table:t2, index:idx_ab(a, b)     | range: decided by [eq(test.t2.a, test.t1.a)]  |
+|   └─TableRowIDScan_10(Probe)  | 1.25    | cop[tikv] | table:t2                         | keep order:false, stats:pseudo                |
++-------------------------------+---------+-----------+----------------------------------+-----------------------------------------------+
+`
+	p, err := ParseText("", result, "v5.4.0")
+	c.Assert(err, IsNil)
+	c.Assert(p.Ver, Equals, PlanVer(V5))
+	c.Assert(p.Root.Type(), Equals, OpTypeIndexJoin)
+	c.Assert(p.Root.Children()[0].ID(), Equals, "TableReader_25(Build)")
+	scan := p.Root.Children()[0].Children()[0].Children()[0].(TableScanOp)
+	c.Assert(scan.Table, Equals, "t1")
+	idx := p.Root.Children()[1].Children()[0].(IndexScanOp)
+	c.Assert(idx.Table, Equals, "t2")
+	c.Assert(idx.Index, Equals, "a, b")
+}
+
+func (s *parseTestSuite) TestParseV6WithEstCost(c *C) {
+	result := `
++-----------------------------+---------+---------+-----------+---------------------------------+--------------------------------+
+| id                          | estRows | estCost | task      | access object                   | operator info                  |
++-----------------------------+---------+---------+-----------+---------------------------------+--------------------------------+
+| Batch_Point_Get_5           | 2.00    | 40.06   | root      | table:t, index:PRIMARY(a)       | keep order:false, desc:false   |
++-----------------------------+---------+---------+-----------+---------------------------------+--------------------------------+
+`
+	p, err := ParseText("", result, "v6.5.0")
+	c.Assert(err, IsNil)
+	c.Assert(p.Ver, Equals, PlanVer(V6))
+	point := p.Root.(PointGetOp)
+	c.Assert(point.Table, Equals, "t")
+	c.Assert(point.Batch, IsTrue)
+	c.Assert(point.EstRow(), Equals, 2.0)
+}
+
+func (s *parseTestSuite) TestParseV7Brief(c *C) {
+	result := `
++------------------------------------+----------+--------------+--------------------------------+----------------------------------------------+
+| id                                 | estRows  | task         | access object                  | operator info                                |
++------------------------------------+----------+--------------+--------------------------------+----------------------------------------------+
+| TableReader                        | 8000.00  | root         |                                | MppVersion: 2, data:ExchangeSender           |
+| └─ExchangeSender                   | 8000.00  | mpp[tiflash] |                                | ExchangeType: PassThrough                    |
+|   └─Projection                     | 8000.00  | mpp[tiflash] |                                | Column#4                                     |
+|     └─HashAgg                      | 8000.00  | mpp[tiflash] |                                | group by:test.t.b, funcs:sum(Column#5)       |
+|       └─ExchangeReceiver           | 8000.00  | mpp[tiflash] |                                |                                              |
+|         └─ExchangeSender           | 8000.00  | mpp[tiflash] |                                | ExchangeType: HashPartition                  |
+|           └─TableFullScan          | 10000.00 | mpp[tiflash] | table:t, partition:p0          | keep order:false, stats:pseudo               |
++------------------------------------+----------+--------------+--------------------------------+----------------------------------------------+
+`
+	p, err := ParseText("", result, "v7.5.1")
+	c.Assert(err, IsNil)
+	c.Assert(p.Ver, Equals, PlanVer(V7))
+	c.Assert(p.Root.ID(), Equals, "TableReader")
+	sender := p.Root.Children()[0]
+	c.Assert(sender.Type(), Equals, OpTypeExchangeSender)
+	c.Assert(sender.Task(), Equals, TaskTypeTiFlash)
+	receiver := sender.Children()[0].Children()[0].Children()[0]
+	c.Assert(receiver.Type(), Equals, OpTypeExchangeReceiver)
+	scan := receiver.Children()[0].Children()[0].(TableScanOp)
+	c.Assert(scan.Table, Equals, "t")
+}
+
+func (s *parseTestSuite) TestFormatVersion(c *C) {
+	cases := map[string]string{
+		"v2.1.0":  V2,
+		"v3.0.12": V3,
+		"v4.0.16": V4,
+		"v5.4.3":  V5,
+		"v6.5.0":  V6,
+		"V7.1.2":  V7,
+		"v8.1.0":  V7,
+		"nightly": V4,
+	}
+	for ver, expected := range cases {
+		c.Assert(formatVersion(ver), Equals, expected)
+	}
+}
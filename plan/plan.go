@@ -11,6 +11,9 @@ const (
 	V2       = "v2"
 	V3       = "v3"
 	V4       = "v4"
+	V5       = "v5"
+	V6       = "v6"
+	V7       = "v7"
 	VUnknown = "unknown"
 )
 
@@ -39,11 +42,16 @@ const (
 	OpTypeTableDual
 	OpTypeSelectLock
 	OpTypeShow
+	OpTypeIndexMerge
+	OpTypeUnion
+	OpTypeWindow
+	OpTypeExchangeSender
+	OpTypeExchangeReceiver
 )
 
 func OpTypeIsDataSource(opType OpType) bool {
 	switch opType {
-	case OpTypeTableReader, OpTypeIndexReader, OpTypeIndexLookup, OpTypePointGet:
+	case OpTypeTableReader, OpTypeIndexReader, OpTypeIndexLookup, OpTypePointGet, OpTypeIndexMerge:
 		return true
 	}
 	return false
@@ -70,6 +78,29 @@ const (
 	JoinTypeAntiLeftOuterSemi
 )
 
+var joinTypeNames = []string{"unknown", "inner join", "left outer join", "right outer join",
+	"semi join", "anti semi join", "left outer semi join", "anti left outer semi join"}
+
+func (t JoinType) String() string {
+	if int(t) < len(joinTypeNames) {
+		return joinTypeNames[t]
+	}
+	return "unknown"
+}
+
+// JoinTypeOf returns the join type of a join operator, or JoinTypeUnknown for other operators.
+func JoinTypeOf(op Operator) JoinType {
+	switch v := op.(type) {
+	case HashJoinOp:
+		return v.JoinType
+	case IndexJoinOp:
+		return v.JoinType
+	case MergeJoinOp:
+		return v.JoinType
+	}
+	return JoinTypeUnknown
+}
+
 type TaskType int
 
 const (
@@ -78,6 +109,18 @@ const (
 	TaskTypeTiFlash
 )
 
+func (t TaskType) String() string {
+	switch t {
+	case TaskTypeRoot:
+		return "root"
+	case TaskTypeTiKV:
+		return "tikv"
+	case TaskTypeTiFlash:
+		return "tiflash"
+	}
+	return "unknown"
+}
+
 type Plan struct {
 	Schema string
 	SQL    string
@@ -96,6 +139,10 @@ type Operator interface {
 	Type() OpType
 	EstRow() float64
 	Task() TaskType
+	// Runtime returns nil if the plan is not from EXPLAIN ANALYZE.
+	Runtime() *RuntimeStats
+	// Row returns the position of the operator in the EXPLAIN result, IDs may be duplicated but rows are not.
+	Row() int
 
 	Format(indent int) string
 	Children() []Operator
@@ -103,10 +150,12 @@ type Operator interface {
 }
 
 type BaseOp struct {
-	id     string
-	opType OpType
-	estRow float64
-	task   TaskType
+	id      string
+	opType  OpType
+	estRow  float64
+	task    TaskType
+	runtime *RuntimeStats
+	row     int
 
 	children []Operator
 }
@@ -127,6 +176,14 @@ func (op BaseOp) Task() TaskType {
 	return op.task
 }
 
+func (op BaseOp) Runtime() *RuntimeStats {
+	return op.runtime
+}
+
+func (op BaseOp) Row() int {
+	return op.row
+}
+
 func (op BaseOp) Format(indent int) string {
 	buf := bytes.NewBuffer(nil)
 	buf.WriteString(strings.Repeat(" ", indent))
@@ -177,6 +234,8 @@ type TableReaderOp struct {
 type TableScanOp struct {
 	BaseOp
 	Table string
+	// FullScan is whether the scan reads the whole table, v2/v3 mark it only by the range in the operator info.
+	FullScan bool
 }
 
 func (op TableScanOp) Format(indent int) string {
@@ -260,3 +319,23 @@ type SelectLock struct {
 type ShowOp struct {
 	BaseOp
 }
+
+type IndexMergeOp struct {
+	BaseOp
+}
+
+type UnionOp struct {
+	BaseOp
+}
+
+type WindowOp struct {
+	BaseOp
+}
+
+type ExchangeSenderOp struct {
+	BaseOp
+}
+
+type ExchangeReceiverOp struct {
+	BaseOp
+}
@@ -0,0 +1,170 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// renderNode is an operator with a unique node name in the diagram.
+type renderNode struct {
+	name  string
+	label []string
+	diff  bool
+}
+
+// renderTree flattens the operator tree in pre-order, nodes whose rows are in diffRows are highlighted.
+func renderTree(root Operator, prefix string, diffRows map[int]struct{}) (nodes []renderNode, edges [][2]string) {
+	if root == nil {
+		return nil, nil
+	}
+	var walk func(op Operator) string
+	walk = func(op Operator) string {
+		name := fmt.Sprintf("%v%v", prefix, len(nodes))
+		_, diff := diffRows[op.Row()]
+		nodes = append(nodes, renderNode{name: name, label: nodeLabel(op), diff: diff})
+		for _, child := range op.Children() {
+			edges = append(edges, [2]string{name, walk(child)})
+		}
+		return name
+	}
+	walk(root)
+	return nodes, edges
+}
+
+func nodeLabel(op Operator) []string {
+	label := []string{op.ID()}
+	switch v := op.(type) {
+	case TableScanOp:
+		label = append(label, "table:"+v.Table)
+	case IndexScanOp:
+		label = append(label, "table:"+v.Table+", index:"+v.Index)
+	case PointGetOp:
+		label = append(label, "table:"+v.Table)
+	}
+	return append(label, fmt.Sprintf("estRows: %.2f", op.EstRow()))
+}
+
+// DiffRows returns rows of differing operators in the first and the second plan,
+// operators are located by rows since their IDs may be duplicated, for example in brief format.
+func DiffRows(entries []DiffEntry) (map[int]struct{}, map[int]struct{}) {
+	rows1, rows2 := make(map[int]struct{}), make(map[int]struct{})
+	for _, e := range entries {
+		if e.OldID != "" {
+			rows1[e.OldRow] = struct{}{}
+		}
+		if e.NewID != "" {
+			rows2[e.NewRow] = struct{}{}
+		}
+	}
+	return rows1, rows2
+}
+
+const highlightColor = "#ffd7d5"
+
+// ToDOT exports the plan as a Graphviz DOT digraph.
+func ToDOT(p Plan) string {
+	var b strings.Builder
+	b.WriteString("digraph plan {\n")
+	b.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+	writeDOTTree(&b, p.Root, "n", nil, "  ")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// DiffToDOT exports two plans side by side as a DOT digraph, differing operators are highlighted.
+func DiffToDOT(p1, p2 Plan, d PlanDiff) string {
+	rows1, rows2 := DiffRows(d.Entries)
+	var b strings.Builder
+	b.WriteString("digraph plan_diff {\n")
+	b.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+	b.WriteString("  subgraph cluster_plan1 {\n    label=\"Plan1\";\n")
+	writeDOTTree(&b, p1.Root, "a", rows1, "    ")
+	b.WriteString("  }\n")
+	b.WriteString("  subgraph cluster_plan2 {\n    label=\"Plan2\";\n")
+	writeDOTTree(&b, p2.Root, "b", rows2, "    ")
+	b.WriteString("  }\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeDOTTree(b *strings.Builder, root Operator, prefix string, diffRows map[int]struct{}, indent string) {
+	nodes, edges := renderTree(root, prefix, diffRows)
+	for _, n := range nodes {
+		lines := make([]string, 0, len(n.label))
+		for _, l := range n.label {
+			lines = append(lines, dotEscape(l))
+		}
+		fmt.Fprintf(b, "%v%v [label=\"%v\"", indent, n.name, strings.Join(lines, "\\n"))
+		if n.diff {
+			fmt.Fprintf(b, ", style=filled, fillcolor=\"%v\"", highlightColor)
+		}
+		b.WriteString("];\n")
+	}
+	for _, e := range edges {
+		fmt.Fprintf(b, "%v%v -> %v;\n", indent, e[0], e[1])
+	}
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func dotEscape(s string) string {
+	return dotEscaper.Replace(s)
+}
+
+// ToMermaid exports the plan as a Mermaid flowchart.
+func ToMermaid(p Plan) string {
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	diff := writeMermaidTree(&b, p.Root, "n", nil, "  ")
+	writeMermaidHighlight(&b, diff)
+	return b.String()
+}
+
+// DiffToMermaid exports two plans side by side as a Mermaid flowchart, differing operators are highlighted.
+func DiffToMermaid(p1, p2 Plan, d PlanDiff) string {
+	rows1, rows2 := DiffRows(d.Entries)
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	b.WriteString("  subgraph plan1 [\"Plan1\"]\n")
+	diff := writeMermaidTree(&b, p1.Root, "a", rows1, "    ")
+	b.WriteString("  end\n")
+	b.WriteString("  subgraph plan2 [\"Plan2\"]\n")
+	diff = append(diff, writeMermaidTree(&b, p2.Root, "b", rows2, "    ")...)
+	b.WriteString("  end\n")
+	writeMermaidHighlight(&b, diff)
+	return b.String()
+}
+
+// writeMermaidTree returns names of highlighted nodes.
+func writeMermaidTree(b *strings.Builder, root Operator, prefix string, diffRows map[int]struct{}, indent string) []string {
+	nodes, edges := renderTree(root, prefix, diffRows)
+	var diff []string
+	for _, n := range nodes {
+		lines := make([]string, 0, len(n.label))
+		for _, l := range n.label {
+			lines = append(lines, mermaidEscape(l))
+		}
+		fmt.Fprintf(b, "%v%v[\"%v\"]\n", indent, n.name, strings.Join(lines, "<br/>"))
+		if n.diff {
+			diff = append(diff, n.name)
+		}
+	}
+	for _, e := range edges {
+		fmt.Fprintf(b, "%v%v --> %v\n", indent, e[0], e[1])
+	}
+	return diff
+}
+
+func writeMermaidHighlight(b *strings.Builder, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "  classDef diff fill:%v,stroke:#c00\n", highlightColor)
+	fmt.Fprintf(b, "  class %v diff\n", strings.Join(names, ","))
+}
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+func mermaidEscape(s string) string {
+	return mermaidEscaper.Replace(s)
+}
@@ -0,0 +1,103 @@
+package plan
+
+import (
+	"strings"
+
+	. "github.com/pingcap/check"
+)
+
+func (s *parseTestSuite) TestRenderPlan(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	p1, err := Parse(V5, "", header, [][]string{
+		{"IndexLookUp_10", "10.00", "root", "", ""},
+		{"├─IndexRangeScan_8(Build)", "10.00", "cop[tikv]", "table:t, index:b(b)", ""},
+		{"└─TableRowIDScan_9(Probe)", "10.00", "cop[tikv]", "table:t", ""},
+	})
+	c.Assert(err, IsNil)
+	p2, err := Parse(V5, "", header, [][]string{
+		{"TableReader_7", "10.00", "root", "", ""},
+		{"└─TableFullScan_5", "10000.00", "cop[tikv]", "table:t", ""},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(ToDOT(p2), Equals, `digraph plan {
+  node [shape=box, fontname="monospace"];
+  n0 [label="TableReader_7\nestRows: 10.00"];
+  n1 [label="TableFullScan_5\ntable:t\nestRows: 10000.00"];
+  n0 -> n1;
+}
+`)
+	c.Assert(ToMermaid(p2), Equals, `graph TD
+  n0["TableReader_7<br/>estRows: 10.00"]
+  n1["TableFullScan_5<br/>table:t<br/>estRows: 10000.00"]
+  n0 --> n1
+`)
+
+	d := CompareDetailed(p1, p2)
+	c.Assert(DiffToDOT(p1, p2, d), Equals, `digraph plan_diff {
+  node [shape=box, fontname="monospace"];
+  subgraph cluster_plan1 {
+    label="Plan1";
+    a0 [label="IndexLookUp_10\nestRows: 10.00", style=filled, fillcolor="#ffd7d5"];
+    a1 [label="IndexRangeScan_8(Build)\ntable:t, index:b\nestRows: 10.00"];
+    a2 [label="TableRowIDScan_9(Probe)\ntable:t\nestRows: 10.00"];
+    a0 -> a1;
+    a0 -> a2;
+  }
+  subgraph cluster_plan2 {
+    label="Plan2";
+    b0 [label="TableReader_7\nestRows: 10.00", style=filled, fillcolor="#ffd7d5"];
+    b1 [label="TableFullScan_5\ntable:t\nestRows: 10000.00"];
+    b0 -> b1;
+  }
+}
+`)
+	c.Assert(DiffToMermaid(p1, p2, d), Equals, `graph TD
+  subgraph plan1 ["Plan1"]
+    a0["IndexLookUp_10<br/>estRows: 10.00"]
+    a1["IndexRangeScan_8(Build)<br/>table:t, index:b<br/>estRows: 10.00"]
+    a2["TableRowIDScan_9(Probe)<br/>table:t<br/>estRows: 10.00"]
+    a0 --> a1
+    a0 --> a2
+  end
+  subgraph plan2 ["Plan2"]
+    b0["TableReader_7<br/>estRows: 10.00"]
+    b1["TableFullScan_5<br/>table:t<br/>estRows: 10000.00"]
+    b0 --> b1
+  end
+  classDef diff fill:#ffd7d5,stroke:#c00
+  class a0,b0 diff
+`)
+	c.Assert(dotEscape(`a"b\c`), Equals, `a\"b\\c`)
+	c.Assert(mermaidEscape(`a"<b>`), Equals, "a#quot;#lt;b#gt;")
+}
+
+func (s *parseTestSuite) TestRenderDuplicatedIDs(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	p1, err := Parse(V7, "", header, [][]string{
+		{"TableReader", "10.00", "root", "", ""},
+		{"└─ExchangeSender", "10.00", "mpp[tiflash]", "", ""},
+		{"  └─ExchangeReceiver", "10.00", "mpp[tiflash]", "", ""},
+		{"    └─ExchangeSender", "10.00", "mpp[tiflash]", "", ""},
+		{"      └─TableFullScan", "10.00", "mpp[tiflash]", "table:t", ""},
+	})
+	c.Assert(err, IsNil)
+	p2, err := Parse(V7, "", header, [][]string{
+		{"TableReader", "10.00", "root", "", ""},
+		{"└─ExchangeSender", "10.00", "mpp[tiflash]", "", ""},
+		{"  └─ExchangeReceiver", "10.00", "mpp[tiflash]", "", ""},
+		{"    └─HashAgg", "10.00", "mpp[tiflash]", "", ""},
+		{"      └─TableFullScan", "10.00", "mpp[tiflash]", "table:t", ""},
+	})
+	c.Assert(err, IsNil)
+
+	d := CompareDetailed(p1, p2)
+	c.Assert(d.Entries, HasLen, 1)
+	c.Assert(d.Entries[0].OldRow, Equals, 3)
+	rows1, rows2 := DiffRows(d.Entries)
+	c.Assert(rows1, DeepEquals, map[int]struct{}{3: {}})
+	c.Assert(rows2, DeepEquals, map[int]struct{}{3: {}})
+	// only the differing ExchangeSender is highlighted, not the other one with the same ID
+	c.Assert(strings.HasSuffix(DiffToMermaid(p1, p2, d), "  class a3,b3 diff\n"), IsTrue)
+	c.Assert(strings.Count(DiffToDOT(p1, p2, d), "fillcolor"), Equals, 2)
+}
@@ -0,0 +1,218 @@
+package plan
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// Rule is an equivalence policy used when comparing plans, a rule takes effect
+// by implementing one or more of NormalizeRule, OperatorRule, SubtreeRule and ChildOrderRule.
+type Rule interface {
+	Name() string
+}
+
+// NormalizeRule replaces an operator with another one in its subtree before comparing,
+// it returns false if nothing should be replaced and must not modify op.
+type NormalizeRule interface {
+	Rule
+	Normalize(op Operator) (Operator, bool)
+}
+
+// OperatorRule treats two operators with different types as the same, their children are still compared.
+type OperatorRule interface {
+	Rule
+	SameOperator(op1, op2 Operator) bool
+}
+
+// SubtreeRule treats two subtrees as the same without comparing their children.
+type SubtreeRule interface {
+	Rule
+	SameSubtree(op1, op2 Operator, tblAlias map[string]string) bool
+}
+
+// ChildOrderRule allows the children of two operators to be matched in any order.
+type ChildOrderRule interface {
+	Rule
+	IgnoreChildOrder(op1, op2 Operator) bool
+}
+
+type removeProjectionRule struct{}
+
+func (removeProjectionRule) Name() string { return "remove_projection" }
+
+func (removeProjectionRule) Normalize(op Operator) (Operator, bool) {
+	if op.Type() == OpTypeProjection && len(op.Children()) == 1 {
+		return op.Children()[0], true
+	}
+	return op, false
+}
+
+type pointGetRule struct{}
+
+func (pointGetRule) Name() string { return "point_get" }
+
+func (pointGetRule) SameSubtree(op1, op2 Operator, tblAlias map[string]string) bool {
+	return specialHandlePointGet(op1, op2, tblAlias)
+}
+
+type ignoreSelectionRule struct{}
+
+func (ignoreSelectionRule) Name() string { return "ignore_selection" }
+
+func (ignoreSelectionRule) Normalize(op Operator) (Operator, bool) {
+	if op.Type() == OpTypeSelection && len(op.Children()) == 1 {
+		return op.Children()[0], true
+	}
+	return op, false
+}
+
+type aggRule struct{}
+
+func (aggRule) Name() string { return "agg_equivalence" }
+
+func (aggRule) SameOperator(op1, op2 Operator) bool {
+	isAgg := func(t OpType) bool { return t == OpTypeHashAgg || t == OpTypeStreamAgg }
+	return isAgg(op1.Type()) && isAgg(op2.Type())
+}
+
+type sortTopNRule struct{}
+
+func (sortTopNRule) Name() string { return "sort_topn" }
+
+// Normalize turns Limit+Sort into Sort, which is the same as TopN by SameOperator.
+func (sortTopNRule) Normalize(op Operator) (Operator, bool) {
+	if op.Type() == OpTypeLimit && len(op.Children()) == 1 && op.Children()[0].Type() == OpTypeSort {
+		return op.Children()[0], true
+	}
+	return op, false
+}
+
+func (sortTopNRule) SameOperator(op1, op2 Operator) bool {
+	isSort := func(t OpType) bool { return t == OpTypeSort || t == OpTypeTopN }
+	return isSort(op1.Type()) && isSort(op2.Type())
+}
+
+type joinChildOrderRule struct{}
+
+func (joinChildOrderRule) Name() string { return "join_child_order" }
+
+func (joinChildOrderRule) IgnoreChildOrder(op1, op2 Operator) bool {
+	return OpTypeIsJoin(op1.Type()) && OpTypeIsJoin(op2.Type())
+}
+
+var builtinRules = []Rule{
+	removeProjectionRule{},
+	pointGetRule{},
+	ignoreSelectionRule{},
+	aggRule{},
+	sortTopNRule{},
+	joinChildOrderRule{},
+}
+
+// DefaultRules are the rules used if no rule is specified.
+var DefaultRules = []Rule{removeProjectionRule{}, pointGetRule{}}
+
+// RuleNames returns names of all builtin rules.
+func RuleNames() []string {
+	names := make([]string, 0, len(builtinRules))
+	for _, r := range builtinRules {
+		names = append(names, r.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RulesByName returns the builtin rules with these names.
+func RulesByName(names []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, r := range builtinRules {
+			if r.Name() == name {
+				rules = append(rules, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown compare rule %v, available rules: %v", name, strings.Join(RuleNames(), ", "))
+		}
+	}
+	return rules, nil
+}
+
+type compareOptions struct {
+	rules             []Rule
+	estRowsDriftRatio float64
+	smallEstRows      float64
+	largeEstRows      float64
+}
+
+// CompareOption customizes how plans are compared.
+type CompareOption func(*compareOptions)
+
+// WithRules replaces the default rules.
+func WithRules(rules ...Rule) CompareOption {
+	return func(opts *compareOptions) {
+		opts.rules = rules
+	}
+}
+
+func newCompareOptions(opts []CompareOption) *compareOptions {
+	o := &compareOptions{rules: DefaultRules, smallEstRows: DefaultSmallEstRows, largeEstRows: DefaultLargeEstRows}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// normalize applies all NormalizeRules until op doesn't change.
+func (o *compareOptions) normalize(op Operator) Operator {
+	for changed := true; changed; {
+		changed = false
+		for _, r := range o.rules {
+			if nr, ok := r.(NormalizeRule); ok {
+				if newOp, ok := nr.Normalize(op); ok {
+					op, changed = newOp, true
+				}
+			}
+		}
+	}
+	return op
+}
+
+func (o *compareOptions) sameOperator(op1, op2 Operator) bool {
+	if op1.Type() == op2.Type() {
+		return true
+	}
+	for _, r := range o.rules {
+		if or, ok := r.(OperatorRule); ok && or.SameOperator(op1, op2) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *compareOptions) sameSubtree(op1, op2 Operator, tblAlias map[string]string) bool {
+	for _, r := range o.rules {
+		if sr, ok := r.(SubtreeRule); ok && sr.SameSubtree(op1, op2, tblAlias) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *compareOptions) ignoreChildOrder(op1, op2 Operator) bool {
+	for _, r := range o.rules {
+		if cr, ok := r.(ChildOrderRule); ok && cr.IgnoreChildOrder(op1, op2) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package plan
+
+import (
+	. "github.com/pingcap/check"
+)
+
+func (s *parseTestSuite) TestCompareRules(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	parse := func(rows [][]string) Plan {
+		p, err := Parse(V5, "", header, rows)
+		c.Assert(err, IsNil)
+		return p
+	}
+	withRules := func(names ...string) CompareOption {
+		rules, err := RulesByName(names)
+		c.Assert(err, IsNil)
+		return WithRules(rules...)
+	}
+
+	hashAgg := parse([][]string{
+		{"HashAgg_5", "10.00", "root", "", ""},
+		{"└─Selection_6", "10.00", "root", "", ""},
+		{"  └─TableReader_7", "10.00", "root", "", ""},
+		{"    └─TableFullScan_8", "10.00", "cop[tikv]", "table:t", ""},
+	})
+	streamAgg := parse([][]string{
+		{"StreamAgg_5", "10.00", "root", "", ""},
+		{"└─TableReader_7", "10.00", "root", "", ""},
+		{"  └─Selection_6", "10.00", "cop[tikv]", "", ""},
+		{"    └─TableFullScan_8", "10.00", "cop[tikv]", "table:t", ""},
+	})
+	_, same := Compare(hashAgg, streamAgg)
+	c.Assert(same, IsFalse)
+	_, same = Compare(hashAgg, streamAgg, withRules("agg_equivalence"))
+	c.Assert(same, IsFalse)
+	_, same = Compare(hashAgg, streamAgg, withRules("agg_equivalence", "ignore_selection"))
+	c.Assert(same, IsTrue)
+
+	topN := parse([][]string{
+		{"TopN_5", "10.00", "root", "", ""},
+		{"└─TableReader_7", "10.00", "root", "", ""},
+		{"  └─TableFullScan_8", "10.00", "cop[tikv]", "table:t", ""},
+	})
+	sortLimit := parse([][]string{
+		{"Limit_4", "10.00", "root", "", ""},
+		{"└─Sort_5", "10.00", "root", "", ""},
+		{"  └─TableReader_7", "10.00", "root", "", ""},
+		{"    └─TableFullScan_8", "10.00", "cop[tikv]", "table:t", ""},
+	})
+	_, same = Compare(topN, sortLimit)
+	c.Assert(same, IsFalse)
+	_, same = Compare(topN, sortLimit, withRules("sort_topn"))
+	c.Assert(same, IsTrue)
+
+	join := func(build, probe string) Plan {
+		return parse([][]string{
+			{"HashJoin_8", "10.00", "root", "", "inner join"},
+			{"├─TableReader_10(Build)", "10.00", "root", "", ""},
+			{"│ └─TableFullScan_9", "10.00", "cop[tikv]", "table:" + build, ""},
+			{"└─TableReader_12(Probe)", "10.00", "root", "", ""},
+			{"  └─TableFullScan_11", "10.00", "cop[tikv]", "table:" + probe, ""},
+		})
+	}
+	_, same = Compare(join("t1", "t2"), join("t2", "t1"))
+	c.Assert(same, IsFalse)
+	_, same = Compare(join("t1", "t2"), join("t2", "t1"), withRules("join_child_order"))
+	c.Assert(same, IsTrue)
+	d := CompareDetailed(join("t1", "t2"), join("t2", "t3"), withRules("join_child_order"))
+	c.Assert(len(d.Entries), Equals, 2)
+
+	_, err := RulesByName([]string{"no_such_rule"})
+	c.Assert(err, NotNil)
+}
+
+func (s *parseTestSuite) TestCompareNotModifyPlans(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	p, err := Parse(V5, "", header, [][]string{
+		{"TableReader_3", "10.00", "root", "", ""},
+		{"└─Projection_4", "10.00", "root", "", ""},
+		{"  └─TableFullScan_5", "10.00", "cop[tikv]", "table:t", ""},
+	})
+	c.Assert(err, IsNil)
+	_, same := Compare(p, p)
+	c.Assert(same, IsTrue)
+	c.Assert(p.Root.Children()[0].Type(), Equals, OpTypeProjection)
+}
@@ -0,0 +1,179 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+const (
+	colActRows  = "actrows"
+	colExecInfo = "execution info"
+	colMemory   = "memory"
+)
+
+// RuntimeStats is the execution metrics of an operator in the result of EXPLAIN ANALYZE.
+type RuntimeStats struct {
+	ActRows float64       `json:"actRows"`
+	Time    time.Duration `json:"time"`
+	// Memory is in bytes, it's -1 if the operator doesn't track its memory usage.
+	Memory int64 `json:"memory"`
+}
+
+var (
+	execTimeRe = regexp.MustCompile(`(?:^|[\s,{])time:\s*([0-9][0-9.]*[a-zµ]+(?:[0-9.]+[a-zµ]+)*)`)
+	execRowsRe = regexp.MustCompile(`(?:^|[\s,{])rows:\s*([0-9]+)`)
+)
+
+// parseRuntimeStats returns nil if the row is not from EXPLAIN ANALYZE.
+func parseRuntimeStats(cols explainColumns, row []string) (*RuntimeStats, error) {
+	_, hasActRows := cols[colActRows]
+	_, hasExecInfo := cols[colExecInfo]
+	if !hasActRows && !hasExecInfo {
+		return nil, nil
+	}
+
+	stats := &RuntimeStats{Memory: -1}
+	execInfo := cols.field(row, colExecInfo)
+	if m := execTimeRe.FindStringSubmatch(execInfo); m != nil {
+		d, err := time.ParseDuration(strings.Replace(m[1], "µ", "u", -1))
+		if err != nil {
+			return nil, errors.Errorf("invalid execution time %v", m[1])
+		}
+		stats.Time = d
+	}
+
+	// TiDB before v4.0 puts actual rows into execution info
+	actRows := cols.field(row, colActRows)
+	if !hasActRows {
+		if m := execRowsRe.FindStringSubmatch(execInfo); m != nil {
+			actRows = m[1]
+		}
+	}
+	if actRows != "" {
+		rows, err := strconv.ParseFloat(actRows, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid actual rows %v", actRows)
+		}
+		stats.ActRows = rows
+	}
+
+	mem, err := parseMemory(cols.field(row, colMemory))
+	if err != nil {
+		return nil, err
+	}
+	stats.Memory = mem
+	return stats, nil
+}
+
+var memoryUnits = map[string]float64{
+	"bytes": 1,
+	"b":     1,
+	"kb":    1 << 10,
+	"mb":    1 << 20,
+	"gb":    1 << 30,
+	"tb":    1 << 40,
+}
+
+// parseMemory parses memory usage like "11.2 KB", it returns -1 for "N/A" or "".
+func parseMemory(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "n/a" {
+		return -1, nil
+	}
+	fields := strings.Fields(s)
+	unit := 1.0
+	if len(fields) == 2 {
+		u, ok := memoryUnits[fields[1]]
+		if !ok {
+			return 0, errors.Errorf("invalid memory usage %v", s)
+		}
+		unit = u
+	} else if len(fields) != 1 {
+		return 0, errors.Errorf("invalid memory usage %v", s)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid memory usage %v", s)
+	}
+	return int64(v * unit), nil
+}
+
+// Analyzed returns whether the plan is from EXPLAIN ANALYZE.
+func (p Plan) Analyzed() bool {
+	return p.Root != nil && p.Root.Runtime() != nil
+}
+
+// Latency returns the execution time of the root operator.
+func (p Plan) Latency() time.Duration {
+	if !p.Analyzed() {
+		return 0
+	}
+	return p.Root.Runtime().Time
+}
+
+// Memory returns the sum of memory usages of all operators.
+func (p Plan) Memory() int64 {
+	var total int64
+	var walk func(op Operator)
+	walk = func(op Operator) {
+		if stats := op.Runtime(); stats != nil && stats.Memory > 0 {
+			total += stats.Memory
+		}
+		for _, child := range op.Children() {
+			walk(child)
+		}
+	}
+	if p.Root != nil {
+		walk(p.Root)
+	}
+	return total
+}
+
+// RuntimeDiff compares the execution metrics of two analyzed plans.
+type RuntimeDiff struct {
+	OldLatency time.Duration `json:"oldLatency"`
+	NewLatency time.Duration `json:"newLatency"`
+	OldMemory  int64         `json:"oldMemory"`
+	NewMemory  int64         `json:"newMemory"`
+	OldActRows float64       `json:"oldActRows"`
+	NewActRows float64       `json:"newActRows"`
+}
+
+// CompareRuntime returns the runtime difference of two plans, it returns false if any of them is not analyzed.
+func CompareRuntime(p1, p2 Plan) (RuntimeDiff, bool) {
+	if !p1.Analyzed() || !p2.Analyzed() {
+		return RuntimeDiff{}, false
+	}
+	return RuntimeDiff{
+		OldLatency: p1.Latency(),
+		NewLatency: p2.Latency(),
+		OldMemory:  p1.Memory(),
+		NewMemory:  p2.Memory(),
+		OldActRows: p1.Root.Runtime().ActRows,
+		NewActRows: p2.Root.Runtime().ActRows,
+	}, true
+}
+
+// LatencyRatio returns the new latency divided by the old one, latencies less than 1µs are regarded as 1µs.
+func (d RuntimeDiff) LatencyRatio() float64 {
+	oldLatency := math.Max(float64(d.OldLatency), float64(time.Microsecond))
+	newLatency := math.Max(float64(d.NewLatency), float64(time.Microsecond))
+	return newLatency / oldLatency
+}
+
+// Regressed returns whether the new latency is more than ratio times of the old one,
+// queries whose new latency is less than minLatency are ignored as noise.
+func (d RuntimeDiff) Regressed(ratio float64, minLatency time.Duration) bool {
+	return d.NewLatency >= minLatency && d.LatencyRatio() > ratio
+}
+
+func (d RuntimeDiff) String() string {
+	return fmt.Sprintf("latency %v -> %v (%.2fx), memory %v -> %v bytes, actual rows %v -> %v",
+		d.OldLatency, d.NewLatency, d.LatencyRatio(), d.OldMemory, d.NewMemory, d.OldActRows, d.NewActRows)
+}
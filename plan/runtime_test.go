@@ -0,0 +1,52 @@
+package plan
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+func (s *parseTestSuite) TestParseRuntimeStats(c *C) {
+	header := []string{"id", "estRows", "actRows", "task", "access object", "execution info", "operator info", "memory", "disk"}
+	rows := [][]string{
+		{"IndexLookUp_10", "10.00", "3", "root", "", "time:1.2ms, loops:2, index_task: {total_time: 800µs}", "", "11.2 KB", "N/A"},
+		{"├─IndexRangeScan_8(Build)", "10.00", "3", "cop[tikv]", "table:t, index:b(b)", "tikv_task:{time:0s, loops:1}", "range:[10,10]", "N/A", "N/A"},
+		{"└─TableRowIDScan_9(Probe)", "10.00", "3", "cop[tikv]", "table:t", "tikv_task:{time:1m2.5s, loops:1}", "keep order:false", "1024 Bytes", "N/A"},
+	}
+	p, err := Parse(V5, "", header, rows)
+	c.Assert(err, IsNil)
+	c.Assert(p.Analyzed(), IsTrue)
+	c.Assert(*p.Root.Runtime(), Equals, RuntimeStats{ActRows: 3, Time: 1200 * time.Microsecond, Memory: 11468})
+	c.Assert(p.Root.Children()[0].Runtime().Memory, Equals, int64(-1))
+	c.Assert(p.Root.Children()[1].Runtime().Time, Equals, time.Minute+2500*time.Millisecond)
+	c.Assert(p.Latency(), Equals, 1200*time.Microsecond)
+	c.Assert(p.Memory(), Equals, int64(11468+1024))
+
+	// TiDB v3 puts actual rows into execution info
+	p3, err := Parse(V3, "", []string{"id", "count", "task", "operator info", "execution info", "memory"}, [][]string{
+		{"TableReader_5", "10000.00", "root", "data:TableScan_4", "time:2.5ms, loops:2, rows:8", "1.5 MB"},
+		{"└─TableScan_4", "10000.00", "cop", "table:t, range:[-inf,+inf]", "time:1ms, loops:1, rows:8", "N/A"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(*p3.Root.Runtime(), Equals, RuntimeStats{ActRows: 8, Time: 2500 * time.Microsecond, Memory: 1572864})
+
+	notAnalyzed, err := Parse(V5, "", []string{"id", "estRows", "task", "access object", "operator info"}, [][]string{
+		{"TableReader_5", "10000.00", "root", "", "data:TableFullScan_4"},
+		{"└─TableFullScan_4", "10000.00", "cop[tikv]", "table:t", ""},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(notAnalyzed.Analyzed(), IsFalse)
+	_, ok := CompareRuntime(p, notAnalyzed)
+	c.Assert(ok, IsFalse)
+
+	d, ok := CompareRuntime(p3, p)
+	c.Assert(ok, IsTrue)
+	c.Assert(d.LatencyRatio(), Equals, 1.2/2.5)
+	c.Assert(d.Regressed(1.5, 0), IsFalse)
+	d, _ = CompareRuntime(p, p3)
+	c.Assert(d.Regressed(1.5, 0), IsTrue)
+	c.Assert(d.Regressed(1.5, 10*time.Millisecond), IsFalse)
+
+	_, err = parseMemory("12 XB")
+	c.Assert(err, NotNil)
+}
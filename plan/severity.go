@@ -0,0 +1,125 @@
+package plan
+
+import (
+	"math"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// Severity is the likely regression risk of a plan change.
+type Severity int
+
+const (
+	SeverityNone Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = []string{"none", "info", "warning", "critical"}
+
+func (s Severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return "unknown"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	sev, err := ParseSeverity(string(text))
+	*s = sev
+	return err
+}
+
+func ParseSeverity(name string) (Severity, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return SeverityNone, errors.Errorf("unknown severity %v", name)
+}
+
+const (
+	// DefaultSmallEstRows is the estimated rows under which a change is considered harmless.
+	DefaultSmallEstRows = 1000.0
+	// DefaultLargeEstRows is the estimated rows above which a full scan or a lost index join is critical.
+	DefaultLargeEstRows = 100000.0
+)
+
+// WithSeverityThresholds replaces DefaultSmallEstRows and DefaultLargeEstRows used to classify differences.
+func WithSeverityThresholds(small, large float64) CompareOption {
+	return func(opts *compareOptions) {
+		opts.smallEstRows, opts.largeEstRows = small, large
+	}
+}
+
+// Severity returns the highest severity of all differences, or SeverityNone if the plans are the same.
+func (d PlanDiff) Severity() Severity {
+	sev := SeverityNone
+	for _, e := range d.Entries {
+		if e.Severity > sev {
+			sev = e.Severity
+		}
+	}
+	return sev
+}
+
+// classify tags a difference by the operator types, tasks and estimated rows of both sides.
+func (o *compareOptions) classify(e DiffEntry) Severity {
+	if e.Kind == DiffSQL {
+		return SeverityWarning
+	}
+	rows := math.Max(maxEstRows(e.Old), maxEstRows(e.New))
+	if rows < o.smallEstRows {
+		return SeverityInfo
+	}
+	switch e.Kind {
+	case DiffAccessPath, DiffOperatorType, DiffSubtreeAdded:
+		if rows >= o.largeEstRows && hasFullScan(e.New) && !hasFullScan(e.Old) {
+			return SeverityCritical
+		}
+	case DiffJoinAlgorithm:
+		if rows >= o.largeEstRows && e.Old.Type() == OpTypeIndexJoin {
+			return SeverityCritical
+		}
+	case DiffTask:
+		if rows >= o.largeEstRows && e.Old.Task() == TaskTypeTiFlash {
+			return SeverityCritical
+		}
+	}
+	return SeverityWarning
+}
+
+// maxEstRows returns the max estimated rows of operators in the subtree.
+func maxEstRows(op Operator) float64 {
+	if op == nil {
+		return 0
+	}
+	rows := op.EstRow()
+	for _, child := range op.Children() {
+		rows = math.Max(rows, maxEstRows(child))
+	}
+	return rows
+}
+
+func hasFullScan(op Operator) bool {
+	if op == nil {
+		return false
+	}
+	if scan, ok := op.(TableScanOp); ok && scan.FullScan {
+		return true
+	}
+	for _, child := range op.Children() {
+		if hasFullScan(child) {
+			return true
+		}
+	}
+	return false
+}
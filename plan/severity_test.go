@@ -0,0 +1,91 @@
+package plan
+
+import (
+	"encoding/json"
+
+	. "github.com/pingcap/check"
+)
+
+func (s *parseTestSuite) TestClassifyDiff(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	parse := func(rows [][]string) Plan {
+		p, err := Parse(V5, "", header, rows)
+		c.Assert(err, IsNil)
+		return p
+	}
+
+	lookup := parse([][]string{
+		{"IndexLookUp_10", "10.00", "root", "", ""},
+		{"├─IndexRangeScan_8(Build)", "10.00", "cop[tikv]", "table:t, index:b(b)", ""},
+		{"└─TableRowIDScan_9(Probe)", "10.00", "cop[tikv]", "table:t", ""},
+	})
+	fullScan := parse([][]string{
+		{"TableReader_7", "10.00", "root", "", ""},
+		{"└─Selection_6", "10.00", "cop[tikv]", "", ""},
+		{"  └─TableFullScan_5", "1000000.00", "cop[tikv]", "table:t", ""},
+	})
+	d := CompareDetailed(lookup, fullScan)
+	c.Assert(d.Entries[0].Kind, Equals, DiffAccessPath)
+	c.Assert(d.Severity(), Equals, SeverityCritical)
+
+	smallScan := parse([][]string{
+		{"TableReader_7", "10.00", "root", "", ""},
+		{"└─Selection_6", "10.00", "cop[tikv]", "", ""},
+		{"  └─TableFullScan_5", "100.00", "cop[tikv]", "table:t", ""},
+	})
+	c.Assert(CompareDetailed(lookup, smallScan).Severity(), Equals, SeverityInfo)
+	// thresholds are passed in by options
+	c.Assert(CompareDetailed(lookup, smallScan, WithSeverityThresholds(10, 100)).Severity(), Equals, SeverityCritical)
+	c.Assert(CompareDetailed(lookup, fullScan, WithSeverityThresholds(1000, 10000000)).Severity(), Equals, SeverityWarning)
+
+	join := func(build, probe string) Plan {
+		return parse([][]string{
+			{"HashJoin_8", "10.00", "root", "", "inner join"},
+			{"├─TableReader_10(Build)", "10.00", "root", "", ""},
+			{"│ └─TableFullScan_9", "10.00", "cop[tikv]", "table:" + build, ""},
+			{"└─TableReader_12(Probe)", "10.00", "root", "", ""},
+			{"  └─TableFullScan_11", "10.00", "cop[tikv]", "table:" + probe, ""},
+		})
+	}
+	d = CompareDetailed(join("t1", "t2"), join("t2", "t1"))
+	c.Assert(len(d.Entries), Equals, 1)
+	c.Assert(d.Entries[0].Kind, Equals, DiffJoinOrder)
+	c.Assert(d.Severity(), Equals, SeverityInfo)
+	c.Assert(CompareDetailed(join("t1", "t2"), join("t1", "t2")).Severity(), Equals, SeverityNone)
+
+	data, err := json.Marshal(CompareDetailed(lookup, smallScan).Entries[0])
+	c.Assert(err, IsNil)
+	var e DiffEntry
+	c.Assert(json.Unmarshal(data, &e), IsNil)
+	c.Assert(e.Severity, Equals, SeverityInfo)
+	c.Assert(e.Kind, Equals, DiffAccessPath)
+}
+
+func (s *parseTestSuite) TestClassifyFullScanV3(c *C) {
+	header := []string{"id", "count", "task", "operator info"}
+	parse := func(rows [][]string) Plan {
+		p, err := Parse(V3, "", header, rows)
+		c.Assert(err, IsNil)
+		return p
+	}
+
+	lookup := parse([][]string{
+		{"IndexLookUp_10", "10.00", "root", ""},
+		{"├─IndexScan_8", "10.00", "cop", "table:t, index:b, range:[1,1], keep order:false"},
+		{"└─TableScan_9", "10.00", "cop", "table:t, keep order:false"},
+	})
+	fullScan := parse([][]string{
+		{"TableReader_7", "10.00", "root", "data:Selection_6"},
+		{"└─Selection_6", "10.00", "cop", "eq(test.t.b, 1)"},
+		{"  └─TableScan_5", "1000000.00", "cop", "table:t, range:[-inf,+inf], keep order:false"},
+	})
+	c.Assert(fullScan.Root.Children()[0].Children()[0].(TableScanOp).FullScan, IsTrue)
+	c.Assert(lookup.Root.Children()[1].(TableScanOp).FullScan, IsFalse)
+	c.Assert(CompareDetailed(lookup, fullScan).Severity(), Equals, SeverityCritical)
+
+	rangeScan := parse([][]string{
+		{"TableReader_7", "10.00", "root", "data:TableScan_5"},
+		{"└─TableScan_5", "1000000.00", "cop", "table:t, range:[1,100000], keep order:false"},
+	})
+	c.Assert(CompareDetailed(lookup, rangeScan).Severity(), Equals, SeverityWarning)
+}
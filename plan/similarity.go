@@ -0,0 +1,161 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SimilarityCosts are the costs of inserting or deleting operators when computing
+// the tree edit distance, an operator costs OpCosts[type] if the type is set,
+// otherwise DefaultCost. Relabeling an operator costs the average of both sides.
+type SimilarityCosts struct {
+	DefaultCost float64
+	OpCosts     map[OpType]float64
+}
+
+// DefaultSimilarityCosts makes changes of joins and data accesses more significant
+// than changes of projections and selections.
+var DefaultSimilarityCosts = SimilarityCosts{
+	DefaultCost: 1,
+	OpCosts: map[OpType]float64{
+		OpTypeHashJoin:    2,
+		OpTypeIndexJoin:   2,
+		OpTypeMergeJoin:   2,
+		OpTypeTableScan:   2,
+		OpTypeIndexScan:   2,
+		OpTypePointGet:    2,
+		OpTypeProjection:  0.5,
+		OpTypeSelection:   0.5,
+		OpTypeMaxOneRow:   0.5,
+		OpTypeSelectLock:  0.5,
+		OpTypeTableReader: 1,
+	},
+}
+
+func (c SimilarityCosts) cost(opType OpType) float64 {
+	if cost, ok := c.OpCosts[opType]; ok {
+		return cost
+	}
+	return c.DefaultCost
+}
+
+// Similarity returns the similarity of two plans in [0, 1] based on the tree edit
+// distance of their operator trees, 1 means the two trees are the same.
+func Similarity(p1, p2 Plan, costs SimilarityCosts) float64 {
+	if p1.Root == nil || p2.Root == nil {
+		return 0
+	}
+	t1, t2 := newEditTree(p1.Root, costs), newEditTree(p2.Root, costs)
+	total := t1.totalCost() + t2.totalCost()
+	if total == 0 {
+		return 1
+	}
+	return 1 - treeEditDistance(t1, t2)/total
+}
+
+// editTree is an operator tree in post-order used by the Zhang-Shasha algorithm.
+type editTree struct {
+	labels []string
+	costs  []float64
+	// lld is the leftmost leaf descendant of each node
+	lld      []int
+	keyRoots []int
+}
+
+func newEditTree(root Operator, costs SimilarityCosts) *editTree {
+	t := new(editTree)
+	var walk func(op Operator) int
+	walk = func(op Operator) int {
+		leftmost := -1
+		for _, child := range op.Children() {
+			if l := walk(child); leftmost == -1 {
+				leftmost = l
+			}
+		}
+		if leftmost == -1 {
+			leftmost = len(t.labels)
+		}
+		t.labels = append(t.labels, editLabel(op))
+		t.costs = append(t.costs, costs.cost(op.Type()))
+		t.lld = append(t.lld, leftmost)
+		return leftmost
+	}
+	walk(root)
+
+	// a key root is the highest node among nodes with the same leftmost leaf
+	seen := make(map[int]struct{})
+	for i := len(t.lld) - 1; i >= 0; i-- {
+		if _, ok := seen[t.lld[i]]; !ok {
+			seen[t.lld[i]] = struct{}{}
+			t.keyRoots = append([]int{i}, t.keyRoots...)
+		}
+	}
+	return t
+}
+
+func (t *editTree) totalCost() float64 {
+	total := 0.0
+	for _, c := range t.costs {
+		total += c
+	}
+	return total
+}
+
+func editLabel(op Operator) string {
+	label := fmt.Sprintf("%v/%v", op.Type(), op.Task())
+	switch v := op.(type) {
+	case TableScanOp:
+		label += "/" + strings.ToLower(v.Table)
+	case IndexScanOp:
+		label += "/" + strings.ToLower(v.Table) + "/" + strings.ToLower(v.Index)
+	case PointGetOp:
+		label += "/" + strings.ToLower(v.Table)
+	}
+	return label
+}
+
+func treeEditDistance(t1, t2 *editTree) float64 {
+	n1, n2 := len(t1.labels), len(t2.labels)
+	td := make([][]float64, n1)
+	for i := range td {
+		td[i] = make([]float64, n2)
+	}
+	relabel := func(i, j int) float64 {
+		if t1.labels[i] == t2.labels[j] {
+			return 0
+		}
+		return (t1.costs[i] + t2.costs[j]) / 2
+	}
+
+	for _, i := range t1.keyRoots {
+		for _, j := range t2.keyRoots {
+			l1, l2 := t1.lld[i], t2.lld[j]
+			fd := make([][]float64, i-l1+2)
+			for x := range fd {
+				fd[x] = make([]float64, j-l2+2)
+			}
+			for x := 1; x < len(fd); x++ {
+				fd[x][0] = fd[x-1][0] + t1.costs[l1+x-1]
+			}
+			for y := 1; y < len(fd[0]); y++ {
+				fd[0][y] = fd[0][y-1] + t2.costs[l2+y-1]
+			}
+			for x := 1; x < len(fd); x++ {
+				for y := 1; y < len(fd[0]); y++ {
+					a, b := l1+x-1, l2+y-1
+					del := fd[x-1][y] + t1.costs[a]
+					ins := fd[x][y-1] + t2.costs[b]
+					if t1.lld[a] == l1 && t2.lld[b] == l2 {
+						fd[x][y] = math.Min(math.Min(del, ins), fd[x-1][y-1]+relabel(a, b))
+						td[a][b] = fd[x][y]
+					} else {
+						p, q := t1.lld[a]-l1, t2.lld[b]-l2
+						fd[x][y] = math.Min(math.Min(del, ins), fd[p][q]+td[a][b])
+					}
+				}
+			}
+		}
+	}
+	return td[n1-1][n2-1]
+}
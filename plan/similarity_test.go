@@ -0,0 +1,51 @@
+package plan
+
+import (
+	. "github.com/pingcap/check"
+)
+
+func (s *parseTestSuite) TestSimilarity(c *C) {
+	header := []string{"id", "estRows", "task", "access object", "operator info"}
+	parse := func(rows [][]string) Plan {
+		p, err := Parse(V5, "", header, rows)
+		c.Assert(err, IsNil)
+		return p
+	}
+	unit := SimilarityCosts{DefaultCost: 1}
+
+	union := parse([][]string{
+		{"Union_8", "20.00", "root", "", ""},
+		{"├─TableReader_10", "10.00", "root", "", ""},
+		{"│ └─TableFullScan_9", "10.00", "cop[tikv]", "table:t1", ""},
+		{"└─TableReader_12", "10.00", "root", "", ""},
+		{"  └─TableFullScan_11", "10.00", "cop[tikv]", "table:t2", ""},
+	})
+	c.Assert(Similarity(union, union, unit), Equals, 1.0)
+	c.Assert(Similarity(union, union, DefaultSimilarityCosts), Equals, 1.0)
+
+	// remove one reader and its scan: distance 2, total 8
+	halfUnion := parse([][]string{
+		{"Union_8", "20.00", "root", "", ""},
+		{"└─TableReader_10", "10.00", "root", "", ""},
+		{"  └─TableFullScan_9", "10.00", "cop[tikv]", "table:t1", ""},
+	})
+	c.Assert(Similarity(union, halfUnion, unit), Equals, 1-2.0/8)
+	c.Assert(Similarity(halfUnion, union, unit), Equals, 1-2.0/8)
+
+	// relabel one scan: distance 1
+	otherTable := parse([][]string{
+		{"Union_8", "20.00", "root", "", ""},
+		{"├─TableReader_10", "10.00", "root", "", ""},
+		{"│ └─TableFullScan_9", "10.00", "cop[tikv]", "table:t1", ""},
+		{"└─TableReader_12", "10.00", "root", "", ""},
+		{"  └─TableFullScan_11", "10.00", "cop[tikv]", "table:t3", ""},
+	})
+	c.Assert(Similarity(union, otherTable, unit), Equals, 1-1.0/10)
+	c.Assert(Similarity(union, otherTable, unit) > Similarity(union, halfUnion, unit), IsTrue)
+
+	pointGet := parse([][]string{
+		{"Point_Get_1", "1.00", "root", "table:t1", ""},
+	})
+	c.Assert(Similarity(pointGet, pointGet, unit), Equals, 1.0)
+	c.Assert(Similarity(union, pointGet, unit) < Similarity(union, halfUnion, unit), IsTrue)
+}